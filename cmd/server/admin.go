@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+)
+
+// serveAdmin accepts server commands, one per line, on a unix socket so a
+// daemon can be controlled without a terminal. quit is called when a client
+// sends the quit command.
+func (s *server) serveAdmin(ctx context.Context, path string, quit func()) error {
+	// A socket left behind by a crashed server would make Listen fail.
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove stale admin socket: %w", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("could not listen on admin socket: %w", err)
+	}
+	// Anyone who can connect can stop the server.
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return fmt.Errorf("could not restrict admin socket: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("admin socket stopped accepting", logging.Err(err))
+				}
+				return
+			}
+			go s.handleAdmin(conn, quit)
+		}
+	}()
+	return nil
+}
+
+func (s *server) handleAdmin(conn net.Conn, quit func()) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
+		err := s.runCommand(words, conn)
+		if errors.Is(err, errQuit) {
+			fmt.Fprintln(conn, "Server is stopping...")
+			quit()
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(conn, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type deadLetter struct {
+	RoutingKey  string `json:"routing_key"`
+	Reason      string `json:"reason"`
+	ContentType string `json:"content_type"`
+	// Timestamp is nil when the publisher didn't set one.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Body      []byte     `json:"body"`
+}
+
+// peekDeadLetters returns up to limit messages of the dead letter queue
+// without consuming them.
+func (s *server) peekDeadLetters(limit int) ([]deadLetter, error) {
+	// A missing queue closes the channel, so don't share it.
+	channel, err := s.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("error during channel creation: %w", err)
+	}
+	// Closing the channel requeues every message we got.
+	defer channel.Close()
+
+	if _, err := channel.QueueDeclarePassive(s.cfg.Queues.DeadLetter, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("error while inspecting dead letter queue: %w", err)
+	}
+	letters := []deadLetter{}
+	for len(letters) < limit {
+		d, ok, err := channel.Get(s.cfg.Queues.DeadLetter, false)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading dead letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+		letter := deadLetter{
+			RoutingKey:  d.RoutingKey,
+			Reason:      deadLetterReason(d),
+			ContentType: d.ContentType,
+			Body:        d.Body,
+		}
+		if !d.Timestamp.IsZero() {
+			letter.Timestamp = &d.Timestamp
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func deadLetterReason(d amqp.Delivery) string {
+	if reason, ok := d.Headers[pubsub.RejectReasonHeader].(string); ok {
+		return reason
+	}
+	// Set by the broker when it dead-letters a message itself.
+	if deaths, ok := d.Headers["x-death"].([]any); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(amqp.Table); ok {
+			if reason, ok := death["reason"].(string); ok {
+				return fmt.Sprintf("%s from %v", reason, death["queue"])
+			}
+		}
+	}
+	return ""
+}
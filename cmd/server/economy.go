@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// collectIncome credits the players with the yield of their regions every
+// tick of the ruleset, unless the game is paused, and sends them their
+// treasury.
+func (s *server) collectIncome(ctx context.Context) {
+	ticker := time.NewTicker(s.world.Rules().Tick())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s.paused.Load() {
+			continue
+		}
+		for username, treasury := range s.world.Collect() {
+			key := fmt.Sprintf("%s.%s", routing.TreasuryPrefix, username)
+			if err := pubsub.PublishJSON(ctx, s.channel, s.cfg.Exchanges.Topic, key, treasury); err != nil {
+				slog.Error("Couldn't publish treasury", logging.KeyUsername, username, logging.Err(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+type subscriptionHealth struct {
+	Queue string `json:"queue"`
+	Alive bool   `json:"alive"`
+	// Messages and Consumers are only read by /readyz.
+	Messages  *int   `json:"messages,omitempty"`
+	Consumers *int   `json:"consumers,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type healthReport struct {
+	Healthy       bool                 `json:"healthy"`
+	Connection    bool                 `json:"connection"`
+	Channel       bool                 `json:"channel"`
+	Subscriptions []subscriptionHealth `json:"subscriptions"`
+	LastLogWrite  *time.Time           `json:"last_log_write,omitempty"`
+}
+
+// health reports the state of the broker connection and of the consumers,
+// without asking the broker anything.
+func (s *server) health() healthReport {
+	report := healthReport{
+		Connection:    !s.conn.IsClosed(),
+		Channel:       !s.channel.IsClosed(),
+		Subscriptions: []subscriptionHealth{},
+	}
+	for _, sub := range s.subs {
+		report.Subscriptions = append(report.Subscriptions, subscriptionHealth{Queue: sub.Queue(), Alive: sub.Alive()})
+	}
+	if last := s.logWriter.LastWrite(); !last.IsZero() {
+		report.LastLogWrite = &last
+	}
+	return report
+}
+
+// readBacklogs fills in the backlog of the queues of report, from the last
+// check of the queue monitor when it has one, otherwise from the broker.
+func (s *server) readBacklogs(report *healthReport) {
+	missing := []string{}
+	for i, h := range report.Subscriptions {
+		if stat, ok := s.monitor.stat(h.Queue); ok {
+			report.Subscriptions[i].Messages, report.Subscriptions[i].Consumers = &stat.Messages, &stat.Consumers
+			continue
+		}
+		missing = append(missing, h.Queue)
+	}
+	if len(missing) == 0 {
+		return
+	}
+	queues, errs := pubsub.InspectQueues(s.conn, missing)
+	for i, h := range report.Subscriptions {
+		if err, ok := errs[h.Queue]; ok {
+			report.Subscriptions[i].Error = err.Error()
+		} else if queue, ok := queues[h.Queue]; ok {
+			report.Subscriptions[i].Messages, report.Subscriptions[i].Consumers = &queue.Messages, &queue.Consumers
+		}
+	}
+}
+
+// handleHealthz fails when the server can't do its main job, writing the
+// game logs, and should be restarted. It is cheap enough to be probed
+// often.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	report := s.health()
+	report.Healthy = report.Connection && s.logSub.Alive()
+	writeHealth(w, report)
+}
+
+// handleReadyz also fails when any consumer or the publishing channel is
+// down, and reports the backlog of the queues.
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := s.health()
+	s.readBacklogs(&report)
+	report.Healthy = report.Connection && report.Channel
+	for _, sub := range report.Subscriptions {
+		report.Healthy = report.Healthy && sub.Alive
+	}
+	writeHealth(w, report)
+}
+
+func writeHealth(w http.ResponseWriter, report healthReport) {
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
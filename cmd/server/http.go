@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logstore"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/metrics"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+const defaultDeadLetters = 20
+
+// serveHTTP starts the admin API on addr. Every request but the health
+// checks must carry token as a bearer token. quit is called by POST
+// /shutdown.
+func (s *server) serveHTTP(ctx context.Context, addr, token string, quit func()) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /pause", s.handlePause(true))
+	mux.HandleFunc("POST /resume", s.handlePause(false))
+	mux.HandleFunc("GET /players", s.handlePlayers)
+	mux.HandleFunc("GET /logs", s.handleLogs)
+	mux.HandleFunc("GET /subscriptions", s.handleSubscriptions)
+	mux.HandleFunc("GET /deadletters", s.handleDeadLetters)
+	mux.HandleFunc("GET /stats", s.handleStats)
+	mux.HandleFunc("GET /world", s.handleWorld)
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("POST /shutdown", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "stopping"})
+		quit()
+	})
+
+	// Supervisors probe the health checks without credentials.
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", s.handleHealthz)
+	root.HandleFunc("GET /readyz", s.handleReadyz)
+	root.Handle("/", requireToken(token, mux))
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+	httpServer := &http.Server{
+		Handler:           root,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin API stopped", logging.Err(err))
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+	return nil
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("could not encode admin API response", logging.Err(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *server) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.publishPause(paused); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+	}
+}
+
+func (s *server) handlePlayers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.players.connected())
+}
+
+type logEntry struct {
+	Time     time.Time `json:"time"`
+	Username string    `json:"username"`
+	Message  string    `json:"message"`
+}
+
+type logsResponse struct {
+	Logs  []logEntry `json:"logs"`
+	Total int        `json:"total"`
+	Page  int        `json:"page"`
+	Pages int        `json:"pages"`
+}
+
+// handleLogs accepts the filters of the logs command as query parameters,
+// e.g. /logs?user=alice&since=10m&contains=war.
+func (s *server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, http.StatusNotFound, errors.New("the game logs database is disabled"))
+		return
+	}
+	filters := []string{}
+	for key, values := range r.URL.Query() {
+		for _, value := range values {
+			filters = append(filters, key+"="+value)
+		}
+	}
+	q, err := logstore.ParseQuery(filters)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := s.store.Query(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := logsResponse{Logs: []logEntry{}, Total: result.Total, Page: result.Page, Pages: result.Pages}
+	for _, gamelog := range result.Logs {
+		resp.Logs = append(resp.Logs, logEntry{Time: gamelog.CurrentTime, Username: gamelog.Username, Message: gamelog.Message})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	infos := []pubsub.SubscriptionInfo{}
+	for _, sub := range s.subs {
+		infos = append(infos, sub.Info())
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *server) handleWorld(w http.ResponseWriter, r *http.Request) {
+	if s.world == nil {
+		writeError(w, http.StatusNotFound, errNoWorld)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.world.Players())
+}
+
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Server.QueueMonitor.Interval <= 0 {
+		s.monitor.check()
+	}
+	writeJSON(w, http.StatusOK, s.monitor.snapshot())
+}
+
+func (s *server) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetters
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("error: %s is not a valid limit", raw))
+			return
+		}
+		limit = n
+	}
+	letters, err := s.peekDeadLetters(limit)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, letters)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logstore"
+)
+
+// queryLogs handles `logs [user=<username>] [since=<duration>] [contains=<text>] [page=<n>] [limit=<n>]`.
+func queryLogs(store *logstore.Store, words []string, out io.Writer) error {
+	if store == nil {
+		return errors.New("the game logs database is disabled")
+	}
+	q, err := logstore.ParseQuery(words)
+	if err != nil {
+		return err
+	}
+	result, err := store.Query(q)
+	if err != nil {
+		return err
+	}
+
+	for _, gamelog := range result.Logs {
+		fmt.Fprintf(out, "%v %v: %v\n", gamelog.CurrentTime.Format(time.RFC3339), gamelog.Username, gamelog.Message)
+	}
+	fmt.Fprintf(out, "Page %d/%d (%d logs)\n", result.Page, max(result.Pages, 1), result.Total)
+	return nil
+}
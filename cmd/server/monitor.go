@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	queueMessages = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "peril",
+		Subsystem: "queue",
+		Name:      "messages",
+		Help:      "Messages ready in a game queue.",
+	}, []string{"queue"})
+	queueConsumers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "peril",
+		Subsystem: "queue",
+		Name:      "consumers",
+		Help:      "Consumers of a game queue.",
+	}, []string{"queue"})
+	queueGrowth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "peril",
+		Subsystem: "queue",
+		Name:      "growth_per_second",
+		Help:      "Change of the ready messages of a game queue since the previous check.",
+	}, []string{"queue"})
+)
+
+type queueStats struct {
+	Queue     string    `json:"queue"`
+	Messages  int       `json:"messages"`
+	Consumers int       `json:"consumers"`
+	Growth    float64   `json:"growth_per_second"`
+	Checked   time.Time `json:"checked"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// queueMonitor periodically reads the backlog of the shared game queues and
+// of the queues of the connected players. The queues of the players are
+// exclusive to their connection, so they are read from the management API,
+// when there is one.
+type queueMonitor struct {
+	conn         *amqp.Connection
+	management   *pubsub.ManagementAPI
+	cfg          config.QueueMonitorConfig
+	queues       func() []string
+	playerQueues func() []string
+	mu           sync.Mutex
+	stats        map[string]queueStats
+	checked      time.Time
+}
+
+func newQueueMonitor(conn *amqp.Connection, management *pubsub.ManagementAPI, cfg config.QueueMonitorConfig, queues, playerQueues func() []string) *queueMonitor {
+	return &queueMonitor{
+		conn:         conn,
+		management:   management,
+		cfg:          cfg,
+		queues:       queues,
+		playerQueues: playerQueues,
+		stats:        map[string]queueStats{},
+	}
+}
+
+// gameQueues lists the shared queues the monitor watches: the game logs and
+// war queues, and the moves and orders queues when the server owns the
+// world.
+func (s *server) gameQueues() []string {
+	queues := []string{s.cfg.Queues.GameLogs, s.cfg.Queues.War}
+	if s.cfg.Server.World {
+		queues = append(queues, s.cfg.Queues.ArmyMoves, s.cfg.Queues.Orders)
+	}
+	return queues
+}
+
+// playerQueues lists the pause and move queues of every connected player.
+func (s *server) playerQueues() []string {
+	queues := []string{}
+	for _, player := range s.players.connected() {
+		queues = append(queues,
+			fmt.Sprintf("%s.%s", routing.PauseKey, player.Username),
+			fmt.Sprintf("%s.%s", routing.ArmyMovesPrefix, player.Username),
+		)
+	}
+	return queues
+}
+
+// inspect reads a queue from the broker, or from the management API for
+// the exclusive queues of the players.
+func (m *queueMonitor) inspect(name string, exclusive bool) (amqp.Queue, error) {
+	if exclusive {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return m.management.InspectQueue(ctx, name)
+	}
+	return pubsub.InspectQueue(m.conn, name)
+}
+
+func (m *queueMonitor) run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		m.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *queueMonitor) check() {
+	now := time.Now()
+	stats := map[string]queueStats{}
+	exclusive := map[string]bool{}
+	names := m.queues()
+	if m.management != nil {
+		for _, name := range m.playerQueues() {
+			exclusive[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		stat := queueStats{Queue: name, Checked: now}
+		queue, err := m.inspect(name, exclusive[name])
+		if err != nil {
+			stat.Error = err.Error()
+			slog.Warn("Couldn't read queue backlog", logging.KeyQueue, name, logging.Err(err))
+			stats[name] = stat
+			continue
+		}
+		stat.Messages, stat.Consumers = queue.Messages, queue.Consumers
+
+		m.mu.Lock()
+		prev, ok := m.stats[name]
+		m.mu.Unlock()
+		if ok && prev.Error == "" {
+			if elapsed := now.Sub(prev.Checked).Seconds(); elapsed > 0 {
+				stat.Growth = float64(stat.Messages-prev.Messages) / elapsed
+			}
+		}
+		stats[name] = stat
+
+		queueMessages.WithLabelValues(name).Set(float64(stat.Messages))
+		queueConsumers.WithLabelValues(name).Set(float64(stat.Consumers))
+		queueGrowth.WithLabelValues(name).Set(stat.Growth)
+		m.warn(stat)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.stats {
+		if _, ok := stats[name]; !ok {
+			queueMessages.DeleteLabelValues(name)
+			queueConsumers.DeleteLabelValues(name)
+			queueGrowth.DeleteLabelValues(name)
+		}
+	}
+	m.stats = stats
+	m.checked = now
+}
+
+func (m *queueMonitor) warn(stat queueStats) {
+	if m.cfg.BacklogWarning > 0 && stat.Messages >= m.cfg.BacklogWarning {
+		slog.Warn("Queue backlog is high", logging.KeyQueue, stat.Queue, "messages", stat.Messages, "consumers", stat.Consumers, "threshold", m.cfg.BacklogWarning)
+	}
+	if m.cfg.GrowthWarning > 0 && stat.Growth >= float64(m.cfg.GrowthWarning) {
+		slog.Warn("Queue backlog is growing fast", logging.KeyQueue, stat.Queue, "messages", stat.Messages, "growth_per_second", stat.Growth, "threshold", m.cfg.GrowthWarning)
+	}
+}
+
+// snapshot returns the stats of the last check, sorted by queue.
+func (m *queueMonitor) snapshot() []queueStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := []queueStats{}
+	for _, stat := range m.stats {
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Queue < stats[j].Queue
+	})
+	return stats
+}
+
+// stat returns the last stats read for a queue, if the monitor runs in the
+// background and could read it.
+func (m *queueMonitor) stat(name string) (queueStats, bool) {
+	if m.cfg.Interval <= 0 {
+		return queueStats{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stat, ok := m.stats[name]
+	return stat, ok && stat.Error == ""
+}
+
+// command handles `stats`, checking the queues first when the monitor
+// doesn't run in the background.
+func (m *queueMonitor) command(out io.Writer) {
+	if m.cfg.Interval <= 0 {
+		m.check()
+	}
+	stats := m.snapshot()
+	fmt.Fprintf(out, "%d queue(s)\n", len(stats))
+	for _, stat := range stats {
+		if stat.Error != "" {
+			fmt.Fprintf(out, "* %s: %s\n", stat.Queue, stat.Error)
+			continue
+		}
+		fmt.Fprintf(out, "* %s: %d message(s), %d consumer(s), %+.1f msg/s\n", stat.Queue, stat.Messages, stat.Consumers, stat.Growth)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// presenceTimeout is how long a player stays connected without announcing
+// itself, a few missed heartbeats.
+const presenceTimeout = 3 * routing.PresenceInterval
+
+type playerInfo struct {
+	Username string    `json:"username"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// presence is the last announcement of a connected player.
+type presence struct {
+	lastSeen    time.Time
+	session     string
+	rulesetHash string
+}
+
+// players tracks who is connected from the presence announcements of the
+// clients.
+type players struct {
+	mu       sync.Mutex
+	presence map[string]presence
+	// onJoin is called when a player who wasn't connected, or who started
+	// another session, announces itself.
+	onJoin func(ctx context.Context, presence routing.PlayerPresence) error
+}
+
+func newPlayers(onJoin func(ctx context.Context, presence routing.PlayerPresence) error) *players {
+	return &players{presence: map[string]presence{}, onJoin: onJoin}
+}
+
+func (p *players) handlerPresence(ctx context.Context, announced routing.PlayerPresence) pubsub.AckType {
+	p.mu.Lock()
+	last, known := p.presence[announced.Username]
+	joined := announced.Online && (!known || last.session != announced.Session || time.Since(last.lastSeen) > presenceTimeout)
+	if announced.Online {
+		p.presence[announced.Username] = presence{lastSeen: time.Now(), session: announced.Session, rulesetHash: announced.RulesetHash}
+	} else {
+		delete(p.presence, announced.Username)
+	}
+	p.mu.Unlock()
+
+	if joined {
+		if err := p.onJoin(ctx, announced); err != nil {
+			slog.Error("Couldn't welcome player", logging.KeyUsername, announced.Username, logging.Err(err))
+		}
+	}
+	return pubsub.Ack
+}
+
+// rulesetHash returns the hash of the rules a connected player announced.
+func (p *players) rulesetHash(username string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last, ok := p.presence[username]
+	if !ok || time.Since(last.lastSeen) > presenceTimeout {
+		return "", false
+	}
+	return last.rulesetHash, true
+}
+
+// connected lists the players heard from recently, sorted by username.
+func (p *players) connected() []playerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	connected := []playerInfo{}
+	for username, last := range p.presence {
+		if time.Since(last.lastSeen) > presenceTimeout {
+			delete(p.presence, username)
+			continue
+		}
+		connected = append(connected, playerInfo{Username: username, LastSeen: last.lastSeen})
+	}
+	sort.Slice(connected, func(i, j int) bool {
+		return connected[i].Username < connected[j].Username
+	})
+	return connected
+}
+
+func (p *players) print(out io.Writer) {
+	connected := p.connected()
+	fmt.Fprintf(out, "%d player(s) connected\n", len(connected))
+	for _, player := range connected {
+		fmt.Fprintf(out, "* %s, last seen %s ago\n", player.Username, time.Since(player.LastSeen).Round(time.Second))
+	}
+}
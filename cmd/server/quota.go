@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const quotaWindow = time.Minute
+
+// quotaLimits are per-minute allowances, zero means unlimited.
+type quotaLimits struct {
+	Messages int
+	Bytes    int
+}
+
+func (l quotaLimits) String() string {
+	format := func(n int) string {
+		if n == 0 {
+			return "unlimited"
+		}
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%s msg/min, %s bytes/min", format(l.Messages), format(l.Bytes))
+}
+
+type quotaUsage struct {
+	windowStart time.Time
+	messages    int
+	bytes       int
+	rejected    int
+}
+
+// quotas limits how many game logs each player can get written.
+type quotas struct {
+	mu         sync.Mutex
+	defaults   quotaLimits
+	overrides  map[string]quotaLimits
+	usage      map[string]*quotaUsage
+	deadLetter bool
+}
+
+func newQuotas(defaults quotaLimits) *quotas {
+	return &quotas{
+		defaults:   defaults,
+		overrides:  map[string]quotaLimits{},
+		usage:      map[string]*quotaUsage{},
+		deadLetter: true,
+	}
+}
+
+func (q *quotas) limitsFor(username string) quotaLimits {
+	if limits, ok := q.overrides[username]; ok {
+		return limits
+	}
+	return q.defaults
+}
+
+// filter is a pubsub.DeliveryFilter for the game_logs.* subscription. A
+// redelivered log was counted, and let through, when it first came.
+func (q *quotas) filter(d amqp.Delivery) *pubsub.Rejection {
+	if d.Redelivered {
+		return nil
+	}
+	username := strings.TrimPrefix(d.RoutingKey, routing.GameLogSlug+".")
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	usage, ok := q.usage[username]
+	if !ok {
+		usage = &quotaUsage{windowStart: now}
+		q.usage[username] = usage
+	}
+	if now.Sub(usage.windowStart) >= quotaWindow {
+		usage.windowStart = now
+		usage.messages = 0
+		usage.bytes = 0
+	}
+
+	limits := q.limitsFor(username)
+	reason := ""
+	if limits.Messages > 0 && usage.messages+1 > limits.Messages {
+		reason = fmt.Sprintf("%s exceeded %d messages per minute", username, limits.Messages)
+	} else if limits.Bytes > 0 && usage.bytes+len(d.Body) > limits.Bytes {
+		reason = fmt.Sprintf("%s exceeded %d bytes per minute", username, limits.Bytes)
+	}
+	if reason != "" {
+		usage.rejected++
+		return &pubsub.Rejection{Reason: reason, DeadLetter: q.deadLetter}
+	}
+	usage.messages++
+	usage.bytes += len(d.Body)
+	return nil
+}
+
+func (q *quotas) print(out io.Writer) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	mode := "drop"
+	if q.deadLetter {
+		mode = "deadletter"
+	}
+	fmt.Fprintf(out, "Default quota: %v (excess: %s)\n", q.defaults, mode)
+
+	usernames := []string{}
+	for username := range q.usage {
+		usernames = append(usernames, username)
+	}
+	for username := range q.overrides {
+		if _, ok := q.usage[username]; !ok {
+			usernames = append(usernames, username)
+		}
+	}
+	sort.Strings(usernames)
+	for _, username := range usernames {
+		fmt.Fprintf(out, "* %s: %v", username, q.limitsFor(username))
+		if usage, ok := q.usage[username]; ok {
+			fmt.Fprintf(out, ", used %d msg / %d bytes, %d rejected", usage.messages, usage.bytes, usage.rejected)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// command handles `quota [set [<username>] <messages> <bytes> | reset <username> | mode drop|deadletter]`.
+func (q *quotas) command(words []string, out io.Writer) error {
+	if len(words) == 0 {
+		q.print(out)
+		return nil
+	}
+
+	switch words[0] {
+	case "set":
+		args := words[1:]
+		username := ""
+		if len(args) == 3 {
+			username, args = args[0], args[1:]
+		}
+		if len(args) != 2 {
+			return errors.New("usage: quota set [<username>] <messages> <bytes>")
+		}
+		messages, err := strconv.Atoi(args[0])
+		if err != nil || messages < 0 {
+			return fmt.Errorf("error: %s is not a valid message count", args[0])
+		}
+		bytes, err := strconv.Atoi(args[1])
+		if err != nil || bytes < 0 {
+			return fmt.Errorf("error: %s is not a valid byte count", args[1])
+		}
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if username == "" {
+			q.defaults = quotaLimits{Messages: messages, Bytes: bytes}
+		} else {
+			q.overrides[username] = quotaLimits{Messages: messages, Bytes: bytes}
+		}
+	case "reset":
+		if len(words) != 2 {
+			return errors.New("usage: quota reset <username>")
+		}
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		delete(q.overrides, words[1])
+	case "mode":
+		if len(words) != 2 || (words[1] != "drop" && words[1] != "deadletter") {
+			return errors.New("usage: quota mode drop|deadletter")
+		}
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.deadLetter = words[1] == "deadletter"
+	default:
+		return fmt.Errorf("unknown quota command: %s", words[0])
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logstore"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// errQuit is returned by runCommand when the server should stop.
+var errQuit = errors.New("quit")
+
+// server holds what the server commands act on, whether they are typed in
+// the REPL or sent to the admin socket.
+type server struct {
+	cfg       config.Config
+	conn      *amqp.Connection
+	channel   *pubsub.Channel
+	subs      []*pubsub.Subscription
+	logSub    *pubsub.Subscription
+	logWriter *gamelogic.LogWriter
+	quotas    *quotas
+	players   *players
+	store     *logstore.Store
+	monitor   *queueMonitor
+	world     *gamelogic.World
+	moves     *gamelogic.MoveValidator
+	// turns is nil when the game is played in real time.
+	turns *turns
+	// gameOver announces the winner only once.
+	gameOver sync.Once
+	// paused stops the income of the players.
+	paused atomic.Bool
+}
+
+func (s *server) publishPause(paused bool) error {
+	err := pubsub.PublishJSON(context.Background(), s.channel, s.cfg.Exchanges.Direct, routing.PauseKey, routing.PlayingState{IsPaused: paused})
+	if err != nil {
+		return fmt.Errorf("something went wrong when publishing message: %v", err)
+	}
+	s.paused.Store(paused)
+	return nil
+}
+
+func (s *server) runCommand(words []string, out io.Writer) error {
+	switch words[0] {
+	case "pause":
+		fmt.Fprintln(out, "Pause the game!")
+		return s.publishPause(true)
+	case "resume":
+		fmt.Fprintln(out, "Resume the game!")
+		return s.publishPause(false)
+	case "quota":
+		return s.quotas.command(words[1:], out)
+	case "logs":
+		return queryLogs(s.store, words[1:], out)
+	case "players":
+		s.players.print(out)
+		return nil
+	case "world":
+		return s.printWorld(words[1:], out)
+	case "stats":
+		s.monitor.command(out)
+		return nil
+	case "help":
+		gamelogic.FprintServerHelp(out)
+		return nil
+	case "quit":
+		return errQuit
+	}
+	return fmt.Errorf("unknown command: %s", words[0])
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// errTurnBased answers the moves and spawns sent one by one in a game played
+// in turns.
+var errTurnBased = errors.New("the game is played in turns, send your orders at the end of the turn")
+
+// turns holds the orders sent for the turn being played.
+type turns struct {
+	mu sync.Mutex
+	// accepting is the turn orders are accepted for, 0 while a turn is
+	// being carried out.
+	accepting int
+	orders    map[string]gamelogic.TurnOrders
+	// started is the start of the last turn, sent to the players joining.
+	started routing.TurnEvent
+}
+
+func newTurns() *turns {
+	return &turns{orders: map[string]gamelogic.TurnOrders{}}
+}
+
+func (t *turns) open(start routing.TurnEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.accepting = start.Turn
+	t.orders = map[string]gamelogic.TurnOrders{}
+	t.started = start
+}
+
+// state returns the start of the last turn, nil when the game is played in
+// real time.
+func (t *turns) state() *routing.TurnEvent {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	started := t.started
+	return &started
+}
+
+// add keeps orders until the end of their turn. Orders sent twice by the
+// same player are carried out one after the other.
+func (t *turns) add(orders gamelogic.TurnOrders) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if orders.Turn != t.accepting {
+		return fmt.Errorf("the orders for turn %d came too late", orders.Turn)
+	}
+	queued := t.orders[orders.Username]
+	queued.Username = orders.Username
+	queued.Turn = orders.Turn
+	queued.Spawns = append(queued.Spawns, orders.Spawns...)
+	queued.Moves = append(queued.Moves, orders.Moves...)
+	t.orders[orders.Username] = queued
+	return nil
+}
+
+// take stops accepting orders for turn and returns them.
+func (t *turns) take(turn int) []gamelogic.TurnOrders {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	orders := []gamelogic.TurnOrders{}
+	if t.accepting != turn {
+		return orders
+	}
+	for _, o := range t.orders {
+		orders = append(orders, o)
+	}
+	t.accepting = 0
+	t.orders = map[string]gamelogic.TurnOrders{}
+	return orders
+}
+
+// runTurns plays the game in turns until ctx is done. No turn starts while
+// the game is paused.
+func (s *server) runTurns(ctx context.Context) {
+	cfg := s.cfg.Server.Turns
+	for turn := 1; ; turn++ {
+		for s.paused.Load() {
+			if !wait(ctx, time.Second) {
+				return
+			}
+		}
+		ends := time.Now().Add(cfg.Duration)
+		start := routing.TurnEvent{Turn: turn, Phase: routing.TurnStart, Ends: ends}
+		s.turns.open(start)
+		s.publishTurn(ctx, start)
+		if !wait(ctx, cfg.Duration) {
+			return
+		}
+		s.publishTurn(ctx, routing.TurnEvent{Turn: turn, Phase: routing.TurnEnd, Ends: ends})
+		if !wait(ctx, cfg.Grace) {
+			return
+		}
+		s.resolveTurn(ctx, turn, s.turns.take(turn))
+	}
+}
+
+// wait sleeps for d and tells whether ctx is still running.
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *server) publishTurn(ctx context.Context, event routing.TurnEvent) {
+	key := fmt.Sprintf("%s.%s", routing.TurnPrefix, event.Phase)
+	if err := pubsub.PublishJSON(ctx, s.channel, s.cfg.Exchanges.Topic, key, event); err != nil {
+		slog.Error("Couldn't publish turn", "turn", event.Turn, "phase", event.Phase, logging.Err(err))
+	}
+}
+
+// resolveTurn carries out the orders of a turn and sends every player their
+// units, with the reasons their orders were rejected.
+func (s *server) resolveTurn(ctx context.Context, turn int, orders []gamelogic.TurnOrders) {
+	result := s.world.ApplyTurn(orders)
+	slog.Info("Turn resolved", "turn", turn, "orders", len(orders), "battles", len(result.Battles), "rejected", len(result.Rejected))
+	reasons := map[string][]string{}
+	for _, rejected := range result.Rejected {
+		slog.Warn("Rejected order", logging.KeyUsername, rejected.Username, "turn", turn, logging.Err(rejected.Err))
+		s.writeGameLog(ctx, rejected.Username, fmt.Sprintf("The server rejected an order of %s: %v", rejected.Username, rejected.Err))
+		reasons[rejected.Username] = append(reasons[rejected.Username], rejected.Err.Error())
+	}
+	for _, battle := range result.Battles {
+		s.reportBattle(ctx, battle)
+	}
+	for _, player := range s.world.Players() {
+		reason := strings.Join(reasons[player.Username], "; ")
+		if err := s.publishPlayerState(ctx, player.Username, reason); err != nil {
+			slog.Error("Couldn't publish player state", logging.KeyUsername, player.Username, logging.Err(err))
+		}
+	}
+	s.checkWinner(ctx)
+}
+
+// handlerOrders keeps the orders of the players until the end of the turn.
+func (s *server) handlerOrders(ctx context.Context, orders gamelogic.TurnOrders) pubsub.AckType {
+	err := s.checkRuleset(orders.Username)
+	if err == nil && s.turns == nil {
+		err = errors.New("the game is played in real time")
+	}
+	if err == nil {
+		err = s.turns.add(orders)
+	}
+	if err != nil {
+		slog.Warn("Rejected orders", logging.KeyUsername, orders.Username, "turn", orders.Turn, logging.KeyOutcome, "nack_discard", logging.Err(err))
+		if err := s.publishPlayerState(ctx, orders.Username, err.Error()); err != nil {
+			slog.Error("Couldn't publish player state", logging.KeyUsername, orders.Username, logging.Err(err))
+		}
+		return pubsub.NackDiscard
+	}
+	return pubsub.Ack
+}
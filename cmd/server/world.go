@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// errNoWorld is returned by the world commands of servers which don't own
+// it.
+var errNoWorld = errors.New("another server owns the world")
+
+// publishPlayerState sends username the units the world gives them. It is
+// also sent when they join so they don't reuse the IDs of their old units.
+// It is returned, and counted, when they have left.
+func publishPlayerState(ctx context.Context, channel pubsub.Publisher, exchange string, world *gamelogic.World, turns *turns, username, reason string) error {
+	key := fmt.Sprintf("%s.%s", routing.WorldStatePrefix, username)
+	state := world.State(username, reason)
+	state.Turn = turns.state()
+	return pubsub.PublishJSON(ctx, channel, exchange, key, state, pubsub.WithMandatory())
+}
+
+func (s *server) publishPlayerState(ctx context.Context, username, reason string) error {
+	return publishPlayerState(ctx, s.channel, s.cfg.Exchanges.Topic, s.world, s.turns, username, reason)
+}
+
+// checkRuleset refuses the actions of players who haven't announced
+// themselves, or who play by other rules.
+func (s *server) checkRuleset(username string) error {
+	hash, ok := s.players.rulesetHash(username)
+	if !ok {
+		return errors.New("the player isn't connected")
+	}
+	if hash != s.world.Rules().Hash() {
+		return errors.New("the player uses another ruleset")
+	}
+	return nil
+}
+
+// handlerAction applies the spawns and moves of the players in the order
+// they made them.
+func (s *server) handlerAction(ctx context.Context, action gamelogic.PlayerAction) pubsub.AckType {
+	var username string
+	switch {
+	case action.Spawn != nil:
+		username = action.Spawn.Username
+	case action.Move != nil:
+		username = action.Move.Player.Username
+	default:
+		slog.Warn("Empty player action", logging.KeyOutcome, "nack_discard")
+		return pubsub.NackDiscard
+	}
+	if err := s.checkRuleset(username); err != nil {
+		slog.Warn("Rejected player action", logging.KeyUsername, username, logging.KeyOutcome, "nack_discard", logging.Err(err))
+		return pubsub.NackDiscard
+	}
+	if action.Spawn != nil {
+		return s.handlerSpawn(ctx, *action.Spawn)
+	}
+	return s.handlerMove(ctx, *action.Move)
+}
+
+// handlerMove applies the moves of every player to the world. A move the
+// world doesn't allow is discarded, and answered with the units the player
+// really has.
+func (s *server) handlerMove(ctx context.Context, move gamelogic.ArmyMove) pubsub.AckType {
+	username := move.Player.Username
+	if s.turns != nil {
+		s.rejectMove(ctx, username, errTurnBased)
+		return pubsub.NackDiscard
+	}
+	battles, err := s.world.ApplyMove(move, s.moves)
+	if err != nil {
+		s.rejectMove(ctx, username, err)
+		return pubsub.NackDiscard
+	}
+
+	notify := []string{username}
+	for _, battle := range battles {
+		s.reportBattle(ctx, battle)
+		notify = append(notify, battle.Defender)
+	}
+	// The move is applied and its wars fought: a redelivery would fight them
+	// again, a state which couldn't be sent is corrected by the next one.
+	for _, player := range notify {
+		if err := s.publishPlayerState(ctx, player, ""); err != nil {
+			slog.Error("Couldn't publish player state", logging.KeyUsername, player, logging.Err(err))
+		}
+	}
+	s.checkWinner(ctx)
+	return pubsub.Ack
+}
+
+// reportBattle records the outcome of battle in the game logs and sends
+// every player its casualties, so they remove exactly the units that died.
+// The server is the only one removing units after a war.
+func (s *server) reportBattle(ctx context.Context, battle gamelogic.Battle) {
+	slog.Info("War resolved", logging.KeyUsername, battle.Attacker, "defender", battle.Defender, "location", battle.Location, logging.KeyOutcome, int(battle.Outcome), "casualties", len(battle.Casualties))
+	switch battle.Outcome {
+	case gamelogic.WarOutcomeYouWon:
+		s.writeGameLog(ctx, battle.Attacker, fmt.Sprintf("%s won a war against %s", battle.Attacker, battle.Defender))
+	case gamelogic.WarOutcomeOpponentWon:
+		s.writeGameLog(ctx, battle.Attacker, fmt.Sprintf("%s won a war against %s", battle.Defender, battle.Attacker))
+	default:
+		s.writeGameLog(ctx, battle.Attacker, fmt.Sprintf("A war between %s and %s resulted in a draw", battle.Attacker, battle.Defender))
+	}
+	key := fmt.Sprintf("%s.%s", routing.CasualtiesPrefix, battle.Attacker)
+	if err := pubsub.PublishJSON(ctx, s.channel, s.cfg.Exchanges.Topic, key, battle); err != nil {
+		slog.Error("Couldn't publish casualty report", logging.KeyUsername, battle.Attacker, logging.Err(err))
+	}
+}
+
+// checkWinner ends the game once the win conditions of the ruleset are met:
+// the winner is recorded in the game logs and the game is paused.
+func (s *server) checkWinner(ctx context.Context) {
+	winner, ok := s.world.Winner()
+	if !ok {
+		return
+	}
+	s.gameOver.Do(func() {
+		slog.Info("Game won", logging.KeyUsername, winner)
+		s.writeGameLog(ctx, winner, fmt.Sprintf("%s won the game", winner))
+		if err := s.publishPause(true); err != nil {
+			slog.Error("Couldn't pause the game", logging.Err(err))
+		}
+	})
+}
+
+// rejectMove records why the move of username was rejected in the game logs
+// and sends them their actual units.
+func (s *server) rejectMove(ctx context.Context, username string, reason error) {
+	slog.Warn("Rejected move", logging.KeyUsername, username, logging.KeyOutcome, "nack_discard", logging.Err(reason))
+	s.writeGameLog(ctx, username, fmt.Sprintf("The server rejected a move of %s: %v", username, reason))
+	if err := s.publishPlayerState(ctx, username, reason.Error()); err != nil {
+		slog.Error("Couldn't publish player state", logging.KeyUsername, username, logging.Err(err))
+	}
+}
+
+// writeGameLog records what the server decided in the game logs, in the
+// trace of the message which made it decide.
+func (s *server) writeGameLog(ctx context.Context, username, message string) {
+	_, span := tracing.Start(ctx, "write game log", attribute.String("peril.username", username))
+	defer span.End()
+	gamelog := routing.GameLog{CurrentTime: time.Now(), Username: username, Message: message}
+	if err := s.logWriter.Write(gamelog); err != nil {
+		span.RecordError(err)
+		slog.Error("Couldn't write game log", logging.KeyUsername, username, logging.Err(err))
+	}
+}
+
+// handlerSpawn adds the units spawned by the players to the world.
+func (s *server) handlerSpawn(ctx context.Context, event gamelogic.SpawnEvent) pubsub.AckType {
+	err := errTurnBased
+	if s.turns == nil {
+		err = s.world.AddUnit(event.Username, event.Unit)
+	}
+	if err != nil {
+		slog.Warn("Invalid spawn", logging.KeyUsername, event.Username, logging.Err(err))
+		if err := s.publishPlayerState(ctx, event.Username, err.Error()); err != nil {
+			slog.Error("Couldn't publish player state", logging.KeyUsername, event.Username, logging.Err(err))
+			return pubsub.NackRequeue
+		}
+		return pubsub.Ack
+	}
+	s.checkWinner(ctx)
+	return pubsub.Ack
+}
+
+// printWorld handles `world [<username>]`.
+func (s *server) printWorld(words []string, out io.Writer) error {
+	if s.world == nil {
+		return errNoWorld
+	}
+	players := s.world.Players()
+	if len(words) > 0 {
+		player, ok := s.world.Player(words[0])
+		if !ok {
+			return fmt.Errorf("unknown player: %s", words[0])
+		}
+		players = []gamelogic.Player{player}
+	}
+	for _, player := range players {
+		treasury := s.world.State(player.Username, "").Treasury
+		fmt.Fprintf(out, "%s has %d units and %d in the treasury (+%d)\n", player.Username, len(player.Units), treasury.Balance, treasury.Income)
+		ids := []int{}
+		for id := range player.Units {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			unit := player.Units[id]
+			fmt.Fprintf(out, "* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+		}
+	}
+	return nil
+}
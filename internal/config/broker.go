@@ -0,0 +1,92 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// AuthPlain authenticates with the credentials of the broker URL.
+	AuthPlain = "plain"
+	// AuthExternal lets the broker authenticate the client certificate.
+	AuthExternal = "external"
+)
+
+// ClientConfig builds the TLS settings of amqps:// connections. The broker
+// certificate is checked against ServerName, or the URL host when empty.
+func (t TLSConfig) ClientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName: t.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, errors.New("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (b BrokerConfig) validate() error {
+	if b.URL == "" {
+		return errors.New("broker URL is required")
+	}
+	uri, err := amqp.ParseURI(b.URL)
+	if err != nil {
+		return fmt.Errorf("invalid broker URL: %w", err)
+	}
+
+	switch b.Auth {
+	case "", AuthPlain:
+	case AuthExternal:
+		if uri.Scheme != "amqps" {
+			return errors.New("external authentication requires an amqps:// broker URL")
+		}
+		if b.TLS.CertFile == "" || b.TLS.KeyFile == "" {
+			return errors.New("external authentication requires a client certificate and key")
+		}
+	default:
+		return fmt.Errorf("unknown authentication mechanism: %s", b.Auth)
+	}
+	return nil
+}
+
+// Dial connects to the broker, over TLS for amqps:// URLs.
+func (b BrokerConfig) Dial() (*amqp.Connection, error) {
+	tlsConfig, err := b.TLS.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var sasl []amqp.Authentication // nil defers to the URL
+	if b.Auth == AuthExternal {
+		sasl = []amqp.Authentication{&amqp.ExternalAuth{}}
+	}
+	return amqp.DialConfig(b.URL, amqp.Config{
+		SASL:            sasl,
+		Vhost:           b.VHost,
+		TLSClientConfig: tlsConfig,
+		Locale:          "en_US",
+	})
+}
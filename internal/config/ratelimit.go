@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// RateLimitConfig throttles the publications of the client whose exchange
+// and routing key match, see pubsub.RateLimit. An empty Exchange matches
+// every exchange.
+type RateLimitConfig struct {
+	Exchange string  `yaml:"exchange"`
+	Pattern  string  `yaml:"pattern"`
+	Rate     float64 `yaml:"rate"` // messages per second
+	Burst    int     `yaml:"burst"`
+}
+
+// RateLimits is set by flags and environment variables as a comma separated
+// list of [exchange/]pattern=rate[:burst], e.g. "peril_topic/game_logs.*=5:10".
+// An empty list disables rate limiting.
+type RateLimits []RateLimitConfig
+
+func (r *RateLimits) String() string {
+	if r == nil {
+		return ""
+	}
+	limits := make([]string, 0, len(*r))
+	for _, l := range *r {
+		limit := fmt.Sprintf("%s=%s:%d", l.Pattern, strconv.FormatFloat(l.Rate, 'g', -1, 64), l.Burst)
+		if l.Exchange != "" {
+			limit = l.Exchange + "/" + limit
+		}
+		limits = append(limits, limit)
+	}
+	return strings.Join(limits, ",")
+}
+
+// Set replaces the rate limits with the ones listed in raw.
+func (r *RateLimits) Set(raw string) error {
+	limits := RateLimits{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		target, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("rate limit %q has no rate", item)
+		}
+		var l RateLimitConfig
+		if exchange, pattern, ok := strings.Cut(target, "/"); ok {
+			l.Exchange, l.Pattern = exchange, pattern
+		} else {
+			l.Pattern = target
+		}
+		rate, burst, hasBurst := strings.Cut(value, ":")
+		var err error
+		if l.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+			return fmt.Errorf("invalid rate in %q: %w", item, err)
+		}
+		l.Burst = 1
+		if hasBurst {
+			if l.Burst, err = strconv.Atoi(burst); err != nil {
+				return fmt.Errorf("invalid burst in %q: %w", item, err)
+			}
+		}
+		limits = append(limits, l)
+	}
+	*r = limits
+	return nil
+}
+
+func (r RateLimits) validate() error {
+	for _, l := range r {
+		if l.Pattern == "" {
+			return errors.New("rate limits need a routing key pattern")
+		}
+		if l.Rate <= 0 {
+			return fmt.Errorf("the rate limit of %s must be positive", l.Pattern)
+		}
+		if l.Burst < 1 {
+			return fmt.Errorf("the burst of the rate limit of %s must be at least 1", l.Pattern)
+		}
+	}
+	return nil
+}
+
+// RateLimits returns the limits the publishing channel of the client
+// applies.
+func (r RateLimits) RateLimits() []pubsub.RateLimit {
+	limits := make([]pubsub.RateLimit, 0, len(r))
+	for _, l := range r {
+		limits = append(limits, pubsub.RateLimit{Exchange: l.Exchange, Pattern: l.Pattern, Rate: l.Rate, Burst: l.Burst})
+	}
+	return limits
+}
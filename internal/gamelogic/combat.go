@@ -0,0 +1,235 @@
+package gamelogic
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// CombatPower is the original rule, the side with the most power wins,
+	// see PowerResolver.
+	CombatPower = "power"
+	// CombatDice rolls a die for every unit, see DiceResolver.
+	CombatDice = "dice"
+)
+
+// CombatResult is the outcome of a war in one location. Every unit of each
+// side is either a casualty or a survivor.
+type CombatResult struct {
+	Outcome WarOutcome // from the attacker's point of view
+	// AttackerPower and DefenderPower are what each side fought with, after
+	// the rolls when there are any.
+	AttackerPower      int
+	DefenderPower      int
+	AttackerCasualties []Unit
+	DefenderCasualties []Unit
+	AttackerSurvivors  []Unit
+	DefenderSurvivors  []Unit
+}
+
+// Casualties returns the units both sides lost.
+func (r CombatResult) Casualties() []Unit {
+	units := make([]Unit, 0, len(r.AttackerCasualties)+len(r.DefenderCasualties))
+	units = append(units, r.AttackerCasualties...)
+	return append(units, r.DefenderCasualties...)
+}
+
+// countSurvivors fills the survivors in with the units that aren't
+// casualties.
+func (r *CombatResult) countSurvivors(attacker, defender []Unit) {
+	r.AttackerSurvivors = survivors(attacker, r.AttackerCasualties)
+	r.DefenderSurvivors = survivors(defender, r.DefenderCasualties)
+}
+
+func survivors(units, casualties []Unit) []Unit {
+	dead := map[int]bool{}
+	for _, unit := range casualties {
+		dead[unit.ID] = true
+	}
+	alive := []Unit{}
+	for _, unit := range sortedByID(units) {
+		if !dead[unit.ID] {
+			alive = append(alive, unit)
+		}
+	}
+	return alive
+}
+
+// CombatResolver decides the outcome of a war between the units two players
+// have in the same location. The same units and seed must always give the
+// same result. Only the server's World resolves wars for real, the players
+// only preview them.
+type CombatResolver interface {
+	Resolve(attacker, defender []Unit, seed int64) CombatResult
+}
+
+// PowerResolver compares the power of both sides, the seed is unused. The
+// loser loses every unit. The winner takes as much damage as the loser has
+// power, and on a draw each side takes half the power of the other: units
+// die, the weakest first, as long as their power fits in the damage left.
+type PowerResolver struct {
+	Rules *Ruleset
+}
+
+func (r PowerResolver) Resolve(attacker, defender []Unit, _ int64) CombatResult {
+	result := CombatResult{
+		AttackerPower: r.Rules.Power(attacker),
+		DefenderPower: r.Rules.Power(defender),
+	}
+	switch {
+	case result.AttackerPower > result.DefenderPower:
+		result.Outcome = WarOutcomeYouWon
+		result.AttackerCasualties = r.absorb(attacker, result.DefenderPower)
+		result.DefenderCasualties = sortedByID(defender)
+	case result.DefenderPower > result.AttackerPower:
+		result.Outcome = WarOutcomeOpponentWon
+		result.AttackerCasualties = sortedByID(attacker)
+		result.DefenderCasualties = r.absorb(defender, result.AttackerPower)
+	default:
+		result.Outcome = WarOutcomeDraw
+		result.AttackerCasualties = r.absorb(attacker, result.DefenderPower/2)
+		result.DefenderCasualties = r.absorb(defender, result.AttackerPower/2)
+	}
+	result.countSurvivors(attacker, defender)
+	return result
+}
+
+// absorb returns the units killed by damage, the weakest first and the
+// lowest IDs first among equals.
+func (r PowerResolver) absorb(units []Unit, damage int) []Unit {
+	units = sortedByID(units)
+	sort.SliceStable(units, func(i, j int) bool {
+		return r.Rules.Power(units[i:i+1]) < r.Rules.Power(units[j:j+1])
+	})
+	dead := []Unit{}
+	for _, unit := range units {
+		power := r.Rules.Power([]Unit{unit})
+		if power > damage {
+			break
+		}
+		damage -= power
+		dead = append(dead, unit)
+	}
+	return dead
+}
+
+// DiceResolver rolls a six-sided die for every unit, multiplied by the
+// power of its rank, and the side with the highest total wins. Each side
+// loses a share of its units equal to the share of the total the other side
+// rolled, the loser at least one, starting with the units that rolled the
+// lowest.
+type DiceResolver struct {
+	Rules *Ruleset
+}
+
+type roll struct {
+	unit  Unit
+	value int
+}
+
+func (r DiceResolver) Resolve(attacker, defender []Unit, seed int64) CombatResult {
+	rng := rand.New(rand.NewSource(seed))
+	attackerRolls := r.roll(rng, attacker)
+	defenderRolls := r.roll(rng, defender)
+	result := CombatResult{AttackerPower: total(attackerRolls), DefenderPower: total(defenderRolls)}
+	switch {
+	case result.AttackerPower > result.DefenderPower:
+		result.Outcome = WarOutcomeYouWon
+	case result.DefenderPower > result.AttackerPower:
+		result.Outcome = WarOutcomeOpponentWon
+	default:
+		result.Outcome = WarOutcomeDraw
+	}
+
+	sum := result.AttackerPower + result.DefenderPower
+	attackerLosses, defenderLosses := 0, 0
+	if sum > 0 {
+		attackerLosses = (len(attackerRolls)*result.DefenderPower + sum/2) / sum
+		defenderLosses = (len(defenderRolls)*result.AttackerPower + sum/2) / sum
+	}
+	switch result.Outcome {
+	case WarOutcomeYouWon:
+		defenderLosses = max(defenderLosses, 1)
+	case WarOutcomeOpponentWon:
+		attackerLosses = max(attackerLosses, 1)
+	}
+	result.AttackerCasualties = weakest(attackerRolls, attackerLosses)
+	result.DefenderCasualties = weakest(defenderRolls, defenderLosses)
+	result.countSurvivors(attacker, defender)
+	return result
+}
+
+// roll rolls for units in the order of their IDs, so the rolls only depend
+// on the seed.
+func (r DiceResolver) roll(rng *rand.Rand, units []Unit) []roll {
+	rolls := make([]roll, 0, len(units))
+	for _, unit := range sortedByID(units) {
+		rolls = append(rolls, roll{unit: unit, value: (rng.Intn(6) + 1) * r.Rules.Power([]Unit{unit})})
+	}
+	return rolls
+}
+
+func total(rolls []roll) int {
+	sum := 0
+	for _, roll := range rolls {
+		sum += roll.value
+	}
+	return sum
+}
+
+// weakest returns the n units that rolled the lowest, the lowest IDs first
+// on a tie.
+func weakest(rolls []roll, n int) []Unit {
+	rolls = append([]roll(nil), rolls...)
+	sort.SliceStable(rolls, func(i, j int) bool {
+		return rolls[i].value < rolls[j].value
+	})
+	units := []Unit{}
+	for _, roll := range rolls[:min(n, len(rolls))] {
+		units = append(units, roll.unit)
+	}
+	return units
+}
+
+func sortedByID(units []Unit) []Unit {
+	units = append([]Unit(nil), units...)
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	return units
+}
+
+// WarSeed derives the seed of a war from the units fighting it. The server
+// seeds its wars with it, so a player whose view of the units is up to date
+// previews the same result.
+func WarSeed(location Location, attacker, defender []Unit) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s", location)
+	for _, units := range [][]Unit{attacker, defender} {
+		for _, unit := range sortedByID(units) {
+			fmt.Fprintf(h, "|%s:%d:%s", unit.Owner, unit.ID, unit.Rank)
+		}
+	}
+	return int64(h.Sum64())
+}
+
+// armySeed derives a seed from the units of a single player, leaving their
+// owner out.
+func armySeed(location Location, units []Unit) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s", location)
+	for _, unit := range sortedByID(units) {
+		fmt.Fprintf(h, "|%d:%s", unit.ID, unit.Rank)
+	}
+	return int64(h.Sum64())
+}
+
+// Resolver returns the combat resolver the ruleset plays with.
+func (r *Ruleset) Resolver() CombatResolver {
+	if r.def.Combat == CombatDice {
+		return DiceResolver{Rules: r}
+	}
+	return PowerResolver{Rules: r}
+}
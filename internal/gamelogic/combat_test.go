@@ -0,0 +1,100 @@
+package gamelogic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testRuleset(t *testing.T, combat string) *Ruleset {
+	t.Helper()
+	def := DefaultRulesetDefinition()
+	def.Combat = combat
+	rules, err := NewRuleset(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+// army returns units of owner in europe with the given ranks, numbered from
+// firstID.
+func army(owner string, firstID int, ranks ...UnitRank) []Unit {
+	units := make([]Unit, 0, len(ranks))
+	for i, rank := range ranks {
+		units = append(units, Unit{ID: firstID + i, Owner: owner, Rank: rank, Location: "europe"})
+	}
+	return units
+}
+
+func reversed(units []Unit) []Unit {
+	out := make([]Unit, 0, len(units))
+	for i := len(units) - 1; i >= 0; i-- {
+		out = append(out, units[i])
+	}
+	return out
+}
+
+func TestResolveIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name     string
+		attacker []Unit
+		defender []Unit
+	}{
+		{"one on one", army("alice", 1, RankInfantry), army("bob", 1, RankInfantry)},
+		{"mixed", army("alice", 1, RankInfantry, RankCavalry, RankArtillery), army("bob", 4, RankCavalry, RankCavalry)},
+		{"outnumbered", army("alice", 1, RankArtillery), army("bob", 1, RankInfantry, RankInfantry, RankInfantry, RankInfantry)},
+		{"no defender", army("alice", 1, RankCavalry), nil},
+	}
+	for _, combat := range []string{CombatPower, CombatDice} {
+		resolver := testRuleset(t, combat).Resolver()
+		for _, tt := range tests {
+			t.Run(combat+"/"+tt.name, func(t *testing.T) {
+				seed := WarSeed("europe", tt.attacker, tt.defender)
+				if other := WarSeed("europe", reversed(tt.attacker), reversed(tt.defender)); other != seed {
+					t.Fatalf("WarSeed depends on the order of the units: %d != %d", seed, other)
+				}
+				want := resolver.Resolve(tt.attacker, tt.defender, seed)
+				for i := 0; i < 3; i++ {
+					got := resolver.Resolve(reversed(tt.attacker), reversed(tt.defender), seed)
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("Resolve() = %+v, want %+v", got, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestPowerResolverKeepsTheOriginalOutcomes(t *testing.T) {
+	resolver := PowerResolver{Rules: testRuleset(t, CombatPower)}
+	tests := []struct {
+		name     string
+		attacker []Unit
+		defender []Unit
+		want     WarOutcome
+	}{
+		{"stronger attacker", army("alice", 1, RankCavalry), army("bob", 1, RankInfantry), WarOutcomeYouWon},
+		{"stronger defender", army("alice", 1, RankInfantry), army("bob", 1, RankArtillery), WarOutcomeOpponentWon},
+		{"equal power", army("alice", 1, RankCavalry), army("bob", 1, RankInfantry, RankInfantry, RankInfantry, RankInfantry, RankInfantry), WarOutcomeDraw},
+		{"same units", army("alice", 1, RankArtillery, RankInfantry), army("bob", 1, RankArtillery, RankInfantry), WarOutcomeDraw},
+		{"many weak units", army("alice", 1, RankInfantry, RankInfantry, RankInfantry, RankInfantry, RankInfantry, RankInfantry), army("bob", 1, RankCavalry), WarOutcomeYouWon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := resolver.Resolve(tt.attacker, tt.defender, 0)
+			if result.Outcome != tt.want {
+				t.Fatalf("Outcome = %v, want %v", result.Outcome, tt.want)
+			}
+			switch tt.want {
+			case WarOutcomeYouWon:
+				if len(result.DefenderCasualties) != len(tt.defender) {
+					t.Errorf("the defender lost %d of %d units", len(result.DefenderCasualties), len(tt.defender))
+				}
+			case WarOutcomeOpponentWon:
+				if len(result.AttackerCasualties) != len(tt.attacker) {
+					t.Errorf("the attacker lost %d of %d units", len(result.AttackerCasualties), len(tt.attacker))
+				}
+			}
+		})
+	}
+}
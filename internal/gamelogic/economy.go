@@ -0,0 +1,118 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Economy is how players earn what they spend on spawning units. Every Tick,
+// each region a player has units in adds its yield to their treasury.
+type Economy struct {
+	StartingBalance int              `json:"starting_balance" yaml:"starting_balance"`
+	Tick            time.Duration    `json:"tick" yaml:"tick"`
+	Yields          map[Location]int `json:"yields" yaml:"yields"`
+}
+
+// Treasury is what a player can spend, and earns every tick.
+type Treasury struct {
+	Balance int
+	Income  int
+}
+
+var defaultEconomy = Economy{
+	StartingBalance: 20,
+	Tick:            10 * time.Second,
+	Yields: map[Location]int{
+		"americas":   3,
+		"europe":     3,
+		"asia":       3,
+		"africa":     2,
+		"australia":  2,
+		"antarctica": 1,
+	},
+}
+
+func (e Economy) validate(m *Map) error {
+	if e.StartingBalance < 0 {
+		return errors.New("the starting balance can't be negative")
+	}
+	if e.Tick <= 0 {
+		return errors.New("the tick must be positive")
+	}
+	for region, yield := range e.Yields {
+		if !m.HasRegion(region) {
+			return fmt.Errorf("%s is not a region of the map", region)
+		}
+		if yield < 0 {
+			return fmt.Errorf("%s can't yield a negative amount", region)
+		}
+	}
+	return nil
+}
+
+// Cost is what spawning a unit of rank takes from the treasury.
+func (r *Ruleset) Cost(rank UnitRank) int {
+	return r.def.Ranks[rank].Cost
+}
+
+// Income adds up the yields of the regions units are in, each region
+// counting once.
+func (r *Ruleset) Income(units []Unit) int {
+	regions := map[Location]bool{}
+	income := 0
+	for _, unit := range units {
+		if !regions[unit.Location] {
+			regions[unit.Location] = true
+			income += r.def.Economy.Yields[unit.Location]
+		}
+	}
+	return income
+}
+
+// Tick is how often players earn their income.
+func (r *Ruleset) Tick() time.Duration {
+	return r.def.Economy.Tick
+}
+
+// StartingBalance is the treasury of a new player.
+func (r *Ruleset) StartingBalance() int {
+	return r.def.Economy.StartingBalance
+}
+
+// CheckCost reports why a player with balance can't afford a unit of rank.
+func (r *Ruleset) CheckCost(balance int, rank UnitRank) error {
+	if cost := r.Cost(rank); cost > balance {
+		return fmt.Errorf("a(n) %s costs %d and the treasury holds %d", rank, cost, balance)
+	}
+	return nil
+}
+
+// Collect credits every player with their income, and returns their
+// treasuries.
+func (w *World) Collect() map[string]Treasury {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	treasuries := make(map[string]Treasury, len(w.players))
+	for username, p := range w.players {
+		income := w.rules.Income(unitSlice(p))
+		w.balances[username] += income
+		treasuries[username] = Treasury{Balance: w.balances[username], Income: income}
+	}
+	return treasuries
+}
+
+// HandleTreasury replaces the treasury of the player with the one the server
+// knows about.
+func (gs *GameState) HandleTreasury(treasury Treasury) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.balance = treasury.Balance
+}
+
+// GetBalance returns what the player can spend.
+func (gs *GameState) GetBalance() int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.balance
+}
@@ -13,8 +13,10 @@ const (
 	RankArtillery = "artillery"
 )
 
+// Unit IDs are allocated by UnitIDs and unique for their owner.
 type Unit struct {
 	ID       int
+	Owner    string
 	Rank     UnitRank
 	Location Location
 }
@@ -25,28 +27,25 @@ type ArmyMove struct {
 	ToLocation Location
 }
 
+// SpawnEvent announces a unit a player just spawned.
+type SpawnEvent struct {
+	Username string
+	Unit     Unit
+}
+
+// PlayerAction is a spawn or a move. Both are published on the army moves
+// key of the player, so they are received in the order they were made.
+type PlayerAction struct {
+	Spawn *SpawnEvent `json:",omitempty"`
+	Move  *ArmyMove   `json:",omitempty"`
+}
+
 type RecognitionOfWar struct {
 	Attacker Player
 	Defender Player
+	// Seed lets the attacker preview the war, the server resolves it with
+	// the seed WarSeed gives for its own view of the units.
+	Seed int64
 }
 
 type Location string
-
-func getAllRanks() map[UnitRank]struct{} {
-	return map[UnitRank]struct{}{
-		RankInfantry:  {},
-		RankCavalry:   {},
-		RankArtillery: {},
-	}
-}
-
-func getAllLocations() map[Location]struct{} {
-	return map[Location]struct{}{
-		"americas":   {},
-		"europe":     {},
-		"africa":     {},
-		"asia":       {},
-		"australia":  {},
-		"antarctica": {},
-	}
-}
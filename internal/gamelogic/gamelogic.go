@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"strings"
@@ -18,6 +19,7 @@ func PrintClientHelp() {
 	fmt.Println("    example:")
 	fmt.Println("    spawn europe infantry")
 	fmt.Println("* status")
+	fmt.Println("* rules")
 	fmt.Println("* spam <n>")
 	fmt.Println("    example:")
 	fmt.Println("    spam 5")
@@ -33,17 +35,36 @@ func ClientWelcome() (string, error) {
 		return "", errors.New("you must enter a username. goodbye")
 	}
 	username := words[0]
+	GreetUser(username)
+	return username, nil
+}
+
+func GreetUser(username string) {
 	fmt.Printf("Welcome, %s!\n", username)
 	PrintClientHelp()
-	return username, nil
 }
 
 func PrintServerHelp() {
-	fmt.Println("Possible commands:")
-	fmt.Println("* pause")
-	fmt.Println("* resume")
-	fmt.Println("* quit")
-	fmt.Println("* help")
+	FprintServerHelp(os.Stdout)
+}
+
+// FprintServerHelp writes the server commands to w, such as an admin
+// connection of a server running as a daemon.
+func FprintServerHelp(w io.Writer) {
+	fmt.Fprintln(w, "Possible commands:")
+	fmt.Fprintln(w, "* pause")
+	fmt.Fprintln(w, "* resume")
+	fmt.Fprintln(w, "* quota [set [<username>] <messages> <bytes> | reset <username> | mode drop|deadletter]")
+	fmt.Fprintln(w, "    example:")
+	fmt.Fprintln(w, "    quota set alice 10 4096")
+	fmt.Fprintln(w, "* logs [user=<username>] [since=<duration>] [contains=<text>] [page=<n>] [limit=<n>]")
+	fmt.Fprintln(w, "    example:")
+	fmt.Fprintln(w, "    logs user=alice since=10m contains=war")
+	fmt.Fprintln(w, "* players")
+	fmt.Fprintln(w, "* world [<username>]")
+	fmt.Fprintln(w, "* stats")
+	fmt.Fprintln(w, "* quit")
+	fmt.Fprintln(w, "* help")
 }
 
 func GetInput() []string {
@@ -58,6 +79,23 @@ func GetInput() []string {
 	return strings.Fields(line)
 }
 
+// ReadInputs calls GetInput in the background so callers can wait for other
+// events too. The channel is closed once stdin is exhausted.
+func ReadInputs() <-chan []string {
+	inputs := make(chan []string)
+	go func() {
+		defer close(inputs)
+		for {
+			words := GetInput()
+			if words == nil {
+				return
+			}
+			inputs <- words
+		}
+	}()
+	return inputs
+}
+
 func GetMaliciousLog() string {
 	possibleLogs := []string{
 		"Never interrupt your enemy when he is making a mistake.",
@@ -84,9 +122,18 @@ func (gs *GameState) CommandStatus() {
 		fmt.Println("The game is not paused.")
 	}
 
+	if gs.InTurnMode() {
+		turn, spawns, moves := gs.getTurnSnap()
+		fmt.Printf("It is turn %d, you queued %d spawn(s) and %d move(s).\n", turn, spawns, moves)
+	}
+
 	p := gs.GetPlayerSnap()
 	fmt.Printf("You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	fmt.Printf("Your treasury holds %d, your regions yield %d every %v.\n", gs.GetBalance(), gs.rules.Income(gs.getUnitsSnap()), gs.rules.Tick())
 	for _, unit := range p.Units {
 		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
 	}
+	for _, opponent := range gs.GetOpponentsSnap() {
+		fmt.Printf("%s has been seen with %d units.\n", opponent.Username, len(opponent.Units))
+	}
 }
@@ -6,21 +6,69 @@ import (
 
 type GameState struct {
 	Player Player
-	Paused bool
-	mu     *sync.RWMutex
+	// Opponents is what the player knows of the units of the others, from
+	// their spawns and moves.
+	Opponents map[string]Player
+	Paused    bool
+	unitIDs   *UnitIDs
+	rules     *Ruleset
+	balance   int
+	turn      turnState
+	mu        *sync.RWMutex
 }
 
-func NewGameState(username string) *GameState {
+func NewGameState(username string, rules *Ruleset) *GameState {
 	return &GameState{
 		Player: Player{
 			Username: username,
 			Units:    map[int]Unit{},
 		},
-		Paused: false,
-		mu:     &sync.RWMutex{},
+		Opponents: map[string]Player{},
+		Paused:    false,
+		unitIDs:   NewUnitIDs(),
+		rules:     rules,
+		balance:   rules.StartingBalance(),
+		mu:        &sync.RWMutex{},
 	}
 }
 
+func (gs *GameState) updateOpponentUnit(username string, u Unit) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	opponent, ok := gs.Opponents[username]
+	if !ok {
+		opponent = Player{Username: username, Units: map[int]Unit{}}
+		gs.Opponents[username] = opponent
+	}
+	opponent.Units[u.ID] = u
+}
+
+// GetOpponent returns a copy of the units known of username.
+func (gs *GameState) GetOpponent(username string) Player {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	units := map[int]Unit{}
+	for k, v := range gs.Opponents[username].Units {
+		units[k] = v
+	}
+	return Player{Username: username, Units: units}
+}
+
+// GetOpponentsSnap returns a copy of the units known of the other players.
+func (gs *GameState) GetOpponentsSnap() map[string]Player {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	opponents := map[string]Player{}
+	for username, opponent := range gs.Opponents {
+		units := map[int]Unit{}
+		for k, v := range opponent.Units {
+			units[k] = v
+		}
+		opponents[username] = Player{Username: username, Units: units}
+	}
+	return opponents
+}
+
 func (gs *GameState) resumeGame() {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
@@ -45,13 +93,23 @@ func (gs *GameState) addUnit(u Unit) {
 	gs.Player.Units[u.ID] = u
 }
 
-func (gs *GameState) removeUnitsInLocation(loc Location) {
+func (gs *GameState) removeUnits(units []Unit) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
-		}
+	for _, unit := range units {
+		delete(gs.Player.Units, unit.ID)
+	}
+}
+
+func (gs *GameState) removeOpponentUnits(username string, units []Unit) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	opponent, ok := gs.Opponents[username]
+	if !ok {
+		return
+	}
+	for _, unit := range units {
+		delete(opponent.Units, unit.ID)
 	}
 }
 
@@ -61,6 +119,10 @@ func (gs *GameState) UpdateUnit(u Unit) {
 	gs.Player.Units[u.ID] = u
 }
 
+func (gs *GameState) GetRules() *Ruleset {
+	return gs.rules
+}
+
 func (gs *GameState) GetUsername() string {
 	return gs.Player.Username
 }
@@ -0,0 +1,238 @@
+package gamelogic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// LogSink persists batches of game logs.
+type LogSink interface {
+	WriteLogs(logs []routing.GameLog) error
+	// Sync makes the logs written so far durable.
+	Sync() error
+	Close() error
+}
+
+// LogFormat encodes a game log as a single line.
+type LogFormat func(gamelog routing.GameLog) ([]byte, error)
+
+// LogFormatText is the historical "<time> <username>: <message>" format.
+func LogFormatText(gamelog routing.GameLog) ([]byte, error) {
+	return []byte(formatLog(gamelog)), nil
+}
+
+type jsonLog struct {
+	Time     time.Time `json:"time"`
+	Username string    `json:"username"`
+	Message  string    `json:"message"`
+}
+
+// LogFormatJSONLines encodes every game log as a JSON object on its own line.
+func LogFormatJSONLines(gamelog routing.GameLog) ([]byte, error) {
+	data, err := json.Marshal(jsonLog{Time: gamelog.CurrentTime, Username: gamelog.Username, Message: gamelog.Message})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode game log: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func encodeLogs(format LogFormat, logs []routing.GameLog) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, gamelog := range logs {
+		line, err := format(gamelog)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// FileSink appends game logs to a single file.
+type FileSink struct {
+	f      *os.File
+	format LogFormat
+}
+
+func NewFileSink(path string, format LogFormat) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open logs file: %v", err)
+	}
+	return &FileSink{f: f, format: format}, nil
+}
+
+func (s *FileSink) WriteLogs(logs []routing.GameLog) error {
+	data, err := encodeLogs(s.format, logs)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(data); err != nil {
+		return fmt.Errorf("could not write to logs file: %v", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Sync() error {
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("could not sync logs file: %v", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+const (
+	LogSinkText     = "text"
+	LogSinkJSON     = "jsonl"
+	LogSinkRotating = "rotating"
+)
+
+// LogSinkConfig selects and configures a LogSink. Format only applies to
+// rotating sinks, which default to the text format.
+type LogSinkConfig struct {
+	Kind     string
+	Path     string
+	Format   string
+	Rotation RotationConfig
+}
+
+func NewLogSink(cfg LogSinkConfig) (LogSink, error) {
+	if cfg.Path == "" {
+		cfg.Path = logsFile
+	}
+
+	switch cfg.Kind {
+	case "", LogSinkText:
+		return NewFileSink(cfg.Path, LogFormatText)
+	case LogSinkJSON:
+		return NewFileSink(cfg.Path, LogFormatJSONLines)
+	case LogSinkRotating:
+		format := LogFormatText
+		switch cfg.Format {
+		case "", LogSinkText:
+		case LogSinkJSON:
+			format = LogFormatJSONLines
+		default:
+			return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+		}
+		return NewRotatingFileSink(cfg.Path, format, cfg.Rotation)
+	}
+	return nil, fmt.Errorf("unknown log sink: %s", cfg.Kind)
+}
+
+// writtenTTL is how long a sink remembers the logs it wrote in a failed
+// batch. A log retried later than that is written twice.
+const writtenTTL = 10 * time.Minute
+
+// multiSink writes every batch to all of its sinks. The logs of a batch a
+// sink failed to write are retried, so the sinks which wrote them before it
+// remember them and skip them once, unless the retry never comes.
+type multiSink struct {
+	sinks []LogSink
+
+	mu      sync.Mutex
+	written []map[logKey]writtenLog // by sink
+}
+
+// writtenLog counts the copies of a log a sink wrote and must skip.
+type writtenLog struct {
+	count int
+	at    time.Time
+}
+
+// logKey identifies a game log, which carries no ID.
+type logKey struct {
+	time     int64
+	username string
+	message  string
+}
+
+func keyOf(gamelog routing.GameLog) logKey {
+	return logKey{time: gamelog.CurrentTime.UnixNano(), username: gamelog.Username, message: gamelog.Message}
+}
+
+// NewMultiSink writes every batch to all of sinks.
+func NewMultiSink(sinks ...LogSink) LogSink {
+	m := &multiSink{sinks: sinks, written: make([]map[logKey]writtenLog, len(sinks))}
+	for i := range m.written {
+		m.written[i] = map[logKey]writtenLog{}
+	}
+	return m
+}
+
+func (m *multiSink) WriteLogs(logs []routing.GameLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.forget(now.Add(-writtenTTL))
+	wrote := make([][]routing.GameLog, len(m.sinks))
+	for i, sink := range m.sinks {
+		pending := []routing.GameLog{}
+		for _, gamelog := range logs {
+			key := keyOf(gamelog)
+			if written, ok := m.written[i][key]; ok {
+				written.count--
+				if written.count == 0 {
+					delete(m.written[i], key)
+				} else {
+					m.written[i][key] = written
+				}
+				continue
+			}
+			pending = append(pending, gamelog)
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		if err := sink.WriteLogs(pending); err != nil {
+			for j := range wrote[:i] {
+				for _, gamelog := range wrote[j] {
+					key := keyOf(gamelog)
+					written := m.written[j][key]
+					m.written[j][key] = writtenLog{count: written.count + 1, at: now}
+				}
+			}
+			return err
+		}
+		wrote[i] = pending
+	}
+	return nil
+}
+
+// forget drops the logs written before cutoff, whose retry didn't come.
+func (m *multiSink) forget(cutoff time.Time) {
+	for _, written := range m.written {
+		for key, entry := range written {
+			if entry.at.Before(cutoff) {
+				delete(written, key)
+			}
+		}
+	}
+}
+
+func (m *multiSink) Sync() error {
+	for _, sink := range m.sinks {
+		if err := sink.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiSink) Close() error {
+	var errs []error
+	for _, sink := range m.sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+package gamelogic
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+type LogSyncMode int
+
+const (
+	// LogSyncNone hands batches to the OS without waiting for the disk.
+	LogSyncNone LogSyncMode = iota
+	// LogSyncBatch syncs the sink after every batch.
+	LogSyncBatch
+)
+
+type LogWriterConfig struct {
+	BatchSize     int
+	FlushInterval time.Duration
+	Sync          LogSyncMode
+}
+
+// LogWriter hands game logs to a LogSink in batches. A batch is flushed once
+// it holds BatchSize logs or FlushInterval has elapsed, whichever comes first.
+type LogWriter struct {
+	cfg     LogWriterConfig
+	sink    LogSink
+	entries chan pendingLog
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	lastWrite atomic.Int64 // unix nanoseconds of the last successful batch
+}
+
+type pendingLog struct {
+	gamelog routing.GameLog
+	result  chan error
+}
+
+var ErrLogWriterClosed = errors.New("log writer is closed")
+
+func NewLogWriter(sink LogSink, cfg LogWriterConfig) *LogWriter {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+
+	w := &LogWriter{
+		cfg:     cfg,
+		sink:    sink,
+		entries: make(chan pendingLog, cfg.BatchSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues gamelog and blocks until the batch holding it has been
+// written, so callers can safely acknowledge the message afterwards.
+func (w *LogWriter) Write(gamelog routing.GameLog) error {
+	result := make(chan error, 1)
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrLogWriterClosed
+	}
+	w.entries <- pendingLog{gamelog: gamelog, result: result}
+	w.mu.RUnlock()
+	return <-result
+}
+
+// LastWrite is when a batch was last written successfully, the zero time if
+// none was.
+func (w *LogWriter) LastWrite() time.Time {
+	nanos := w.lastWrite.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Close flushes the pending logs and closes the sink.
+func (w *LogWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.entries)
+	w.mu.Unlock()
+
+	<-w.done
+	return w.sink.Close()
+}
+
+func (w *LogWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := []pendingLog{}
+	for {
+		select {
+		case entry, ok := <-w.entries:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= w.cfg.BatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (w *LogWriter) flush(batch []pendingLog) {
+	if len(batch) == 0 {
+		return
+	}
+
+	logs := make([]routing.GameLog, 0, len(batch))
+	for _, entry := range batch {
+		logs = append(logs, entry.gamelog)
+	}
+	err := w.sink.WriteLogs(logs)
+	if err == nil && w.cfg.Sync == LogSyncBatch {
+		err = w.sink.Sync()
+	}
+	if err == nil {
+		w.lastWrite.Store(time.Now().UnixNano())
+	}
+
+	for _, entry := range batch {
+		entry.result <- err
+	}
+}
@@ -28,6 +28,9 @@ func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
 	if player.Username == move.Player.Username {
 		return MoveOutcomeSamePlayer
 	}
+	for _, unit := range move.Units {
+		gs.updateOpponentUnit(move.Player.Username, unit)
+	}
 
 	overlappingLocation := getOverlappingLocation(player, move.Player)
 	if overlappingLocation != "" {
@@ -38,15 +41,18 @@ func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
 	return MoveOutComeSafe
 }
 
+// getOverlappingLocation returns a location both players have units in,
+// the first one by name when there are several so everyone picks the same.
 func getOverlappingLocation(p1 Player, p2 Player) Location {
+	overlapping := Location("")
 	for _, u1 := range p1.Units {
 		for _, u2 := range p2.Units {
-			if u1.Location == u2.Location {
-				return u1.Location
+			if u1.Location == u2.Location && (overlapping == "" || u1.Location < overlapping) {
+				overlapping = u1.Location
 			}
 		}
 	}
-	return ""
+	return overlapping
 }
 
 func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
@@ -57,8 +63,7 @@ func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
 		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
 	}
 	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
+	if !gs.rules.Map().HasRegion(newLocation) {
 		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
 	}
 	unitIDs := []int{}
@@ -77,10 +82,15 @@ func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
 		if !ok {
 			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
 		}
+		if err := gs.rules.CheckMove(unit.Rank, unit.Location, newLocation); err != nil {
+			return ArmyMove{}, fmt.Errorf("error: unit %v: %w", unitID, err)
+		}
 		unit.Location = newLocation
-		gs.UpdateUnit(unit)
 		newUnits = append(newUnits, unit)
 	}
+	for _, unit := range newUnits {
+		gs.UpdateUnit(unit)
+	}
 
 	mv := ArmyMove{
 		ToLocation: newLocation,
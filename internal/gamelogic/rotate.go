@@ -0,0 +1,243 @@
+package gamelogic
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const rotationTimeFormat = "20060102T150405.000"
+
+// RotationConfig decides when a RotatingFileSink starts a new segment and
+// what happens to the old ones. Zero values disable the matching rule.
+type RotationConfig struct {
+	MaxBytes   int64
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+}
+
+// RotatingFileSink writes game logs to a file which gets renamed to a
+// timestamped backup once it grows too big or too old.
+type RotatingFileSink struct {
+	path   string
+	format LogFormat
+	cfg    RotationConfig
+
+	f        *os.File // nil until the file can be opened again
+	size     int64
+	openedAt time.Time
+
+	// cleanup serializes compression and pruning of the backups.
+	cleanup sync.Mutex
+	wg      sync.WaitGroup
+}
+
+func NewRotatingFileSink(path string, format LogFormat, cfg RotationConfig) (*RotatingFileSink, error) {
+	s := &RotatingFileSink{path: path, format: format, cfg: cfg}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RotatingFileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open logs file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not stat logs file: %v", err)
+	}
+	s.f = f
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+func (s *RotatingFileSink) WriteLogs(logs []routing.GameLog) error {
+	data, err := encodeLogs(s.format, logs)
+	if err != nil {
+		return err
+	}
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.shouldRotate(int64(len(data))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(data)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write to logs file: %v", err)
+	}
+	return nil
+}
+
+func (s *RotatingFileSink) shouldRotate(incoming int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.cfg.MaxBytes > 0 && s.size+incoming > s.cfg.MaxBytes {
+		return true
+	}
+	return s.cfg.MaxAge > 0 && time.Since(s.openedAt) >= s.cfg.MaxAge
+}
+
+func (s *RotatingFileSink) rotate() error {
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("could not sync logs file: %v", err)
+	}
+	// On failure, keep writing to the same file, it is rotated on the next
+	// write. A file which can't be opened again is opened on the next write.
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+		return fmt.Errorf("could not close logs file: %v", err)
+	}
+
+	backup := s.backupName(time.Now())
+	if err := os.Rename(s.path, backup); err != nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+		return fmt.Errorf("could not rotate logs file: %v", err)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.cleanup.Lock()
+		defer s.cleanup.Unlock()
+		if s.cfg.Compress {
+			if err := compressFile(backup); err != nil {
+				slog.Warn("could not compress rotated game logs", "path", backup, logging.Err(err))
+			}
+		}
+		if err := s.prune(); err != nil {
+			slog.Warn("could not prune rotated game logs", logging.Err(err))
+		}
+	}()
+	return s.open()
+}
+
+// backupName returns a name no backup has, timestamped with now. Backups
+// made within the same millisecond are numbered.
+func (s *RotatingFileSink) backupName(now time.Time) string {
+	ext := filepath.Ext(s.path)
+	stamp := fmt.Sprintf("%s-%s", strings.TrimSuffix(s.path, ext), now.Format(rotationTimeFormat))
+	backup := stamp + ext
+	for n := 1; exists(backup) || exists(backup+".gz"); n++ {
+		backup = fmt.Sprintf("%s_%03d%s", stamp, n, ext)
+	}
+	return backup
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// prune removes the oldest backups beyond MaxBackups.
+func (s *RotatingFileSink) prune() error {
+	if s.cfg.MaxBackups <= 0 {
+		return nil
+	}
+	ext := filepath.Ext(s.path)
+	pattern := fmt.Sprintf("%s-*%s", strings.TrimSuffix(s.path, ext), ext)
+	plain, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	compressed, err := filepath.Glob(pattern + ".gz")
+	if err != nil {
+		return err
+	}
+	// Without an extension, the plain pattern matches the compressed
+	// backups too.
+	backups := []string{}
+	seen := map[string]bool{}
+	for _, backup := range append(plain, compressed...) {
+		if !seen[backup] {
+			seen[backup] = true
+			backups = append(backups, backup)
+		}
+	}
+	if len(backups) <= s.cfg.MaxBackups {
+		return nil
+	}
+
+	// Timestamps sort lexically, oldest first.
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-s.cfg.MaxBackups] {
+		if err := os.Remove(backup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (s *RotatingFileSink) Sync() error {
+	if s.f == nil {
+		return nil
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("could not sync logs file: %v", err)
+	}
+	return nil
+}
+
+func (s *RotatingFileSink) Close() error {
+	var err error
+	if s.f != nil {
+		err = s.f.Close()
+		s.f = nil
+	}
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,308 @@
+package gamelogic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RankRules are the traits of a unit rank.
+type RankRules struct {
+	// Power decides who wins a war, see Ruleset.Power.
+	Power int `json:"power" yaml:"power"`
+	// Movement is how many movement points a unit spends in a single move.
+	Movement int `json:"movement" yaml:"movement"`
+	// Cost is what spawning a unit takes from the treasury.
+	Cost int `json:"cost" yaml:"cost"`
+}
+
+// SpawnLimits cap the units of a player, zero means unlimited.
+type SpawnLimits struct {
+	MaxUnits     int `json:"max_units" yaml:"max_units"`
+	MaxPerRegion int `json:"max_per_region" yaml:"max_per_region"`
+}
+
+// WinConditions end the game. A player wins when they are the only one left
+// with units, if LastStanding is set, or once they alone occupy
+// ControlRegions regions, if it isn't zero.
+type WinConditions struct {
+	LastStanding   bool `json:"last_standing" yaml:"last_standing"`
+	ControlRegions int  `json:"control_regions" yaml:"control_regions"`
+}
+
+// RulesetDefinition is the format of ruleset files, in YAML or JSON:
+//
+//	name: classic
+//	map: {regions: [...], edges: [...]}
+//	ranks:
+//	  infantry: {power: 1, movement: 2, cost: 1}
+//	economy: {starting_balance: 20, tick: 10s, yields: {europe: 3}}
+//	combat: power
+//	spawn_limits: {max_units: 20, max_per_region: 0}
+//	win_conditions: {last_standing: true, control_regions: 0}
+type RulesetDefinition struct {
+	Name    string                 `json:"name" yaml:"name"`
+	Map     MapDefinition          `json:"map" yaml:"map"`
+	Ranks   map[UnitRank]RankRules `json:"ranks" yaml:"ranks"`
+	Economy Economy                `json:"economy" yaml:"economy"`
+	// Combat names the CombatResolver wars are fought with, power or dice.
+	Combat        string        `json:"combat" yaml:"combat"`
+	SpawnLimits   SpawnLimits   `json:"spawn_limits" yaml:"spawn_limits"`
+	WinConditions WinConditions `json:"win_conditions" yaml:"win_conditions"`
+}
+
+// Ruleset is a validated RulesetDefinition. The server and the clients must
+// play by the same one, which they compare with Hash.
+type Ruleset struct {
+	def      RulesetDefinition
+	worldMap *Map
+	hash     string
+}
+
+var defaultRulesetDefinition = RulesetDefinition{
+	Name: "classic",
+	Map:  defaultMapDefinition,
+	Ranks: map[UnitRank]RankRules{
+		RankInfantry:  {Power: 1, Movement: 2, Cost: 1},
+		RankCavalry:   {Power: 5, Movement: 3, Cost: 4},
+		RankArtillery: {Power: 10, Movement: 2, Cost: 8},
+	},
+	Economy:       defaultEconomy,
+	Combat:        CombatPower,
+	WinConditions: WinConditions{LastStanding: true},
+}
+
+// DefaultRulesetDefinition returns the rules of the original game.
+func DefaultRulesetDefinition() RulesetDefinition {
+	def := defaultRulesetDefinition
+	def.Ranks = map[UnitRank]RankRules{}
+	for rank, rules := range defaultRulesetDefinition.Ranks {
+		def.Ranks[rank] = rules
+	}
+	def.Economy.Yields = map[Location]int{}
+	for region, yield := range defaultEconomy.Yields {
+		def.Economy.Yields[region] = yield
+	}
+	return def
+}
+
+// LoadRulesetDefinition reads a ruleset file, which NewRuleset validates.
+// Sections missing from the file keep their default.
+func LoadRulesetDefinition(path string) (RulesetDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RulesetDefinition{}, fmt.Errorf("could not read ruleset file: %w", err)
+	}
+	// JSON is valid YAML.
+	var def RulesetDefinition
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return RulesetDefinition{}, fmt.Errorf("could not parse ruleset file %s: %w", path, err)
+	}
+	defaults := DefaultRulesetDefinition()
+	if def.Name == "" {
+		def.Name = defaults.Name
+	}
+	if len(def.Map.Regions) == 0 {
+		def.Map = defaults.Map
+	}
+	if len(def.Ranks) == 0 {
+		def.Ranks = defaults.Ranks
+	}
+	if def.Economy.Tick == 0 {
+		def.Economy = defaults.Economy
+	}
+	if def.Combat == "" {
+		def.Combat = defaults.Combat
+	}
+	return def, nil
+}
+
+func NewRuleset(def RulesetDefinition) (*Ruleset, error) {
+	worldMap, err := NewMap(def.Map)
+	if err != nil {
+		return nil, fmt.Errorf("invalid map: %w", err)
+	}
+	if len(def.Ranks) == 0 {
+		return nil, errors.New("the ruleset has no ranks")
+	}
+	for rank, rules := range def.Ranks {
+		if rank == "" {
+			return nil, errors.New("ranks need a name")
+		}
+		if rules.Power < 0 {
+			return nil, fmt.Errorf("rank %s can't have a negative power", rank)
+		}
+		if rules.Movement < 0 {
+			return nil, fmt.Errorf("rank %s can't have negative movement", rank)
+		}
+		if rules.Cost < 0 {
+			return nil, fmt.Errorf("rank %s can't have a negative cost", rank)
+		}
+	}
+	if err := def.Economy.validate(worldMap); err != nil {
+		return nil, fmt.Errorf("invalid economy: %w", err)
+	}
+	if def.Combat != CombatPower && def.Combat != CombatDice {
+		return nil, fmt.Errorf("unknown combat resolver: %q", def.Combat)
+	}
+	if def.SpawnLimits.MaxUnits < 0 || def.SpawnLimits.MaxPerRegion < 0 {
+		return nil, errors.New("spawn limits can't be negative")
+	}
+	if def.WinConditions.ControlRegions < 0 || def.WinConditions.ControlRegions > len(def.Map.Regions) {
+		return nil, fmt.Errorf("control_regions must be between 0 and the %d regions of the map", len(def.Map.Regions))
+	}
+
+	// Maps are encoded with sorted keys, so equal rulesets hash the same.
+	data, err := json.Marshal(def)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash ruleset: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return &Ruleset{def: def, worldMap: worldMap, hash: hex.EncodeToString(sum[:])}, nil
+}
+
+func (r *Ruleset) Name() string {
+	return r.def.Name
+}
+
+// Hash identifies the rules, the map included.
+func (r *Ruleset) Hash() string {
+	return r.hash
+}
+
+func (r *Ruleset) Map() *Map {
+	return r.worldMap
+}
+
+func (r *Ruleset) HasRank(rank UnitRank) bool {
+	_, ok := r.def.Ranks[rank]
+	return ok
+}
+
+// Power adds up the power of units.
+func (r *Ruleset) Power(units []Unit) int {
+	power := 0
+	for _, unit := range units {
+		power += r.def.Ranks[unit.Rank].Power
+	}
+	return power
+}
+
+// CheckMove reports why a unit of rank can't travel from one region to
+// another in one move.
+func (r *Ruleset) CheckMove(rank UnitRank, from, to Location) error {
+	if !r.worldMap.HasRegion(to) {
+		return fmt.Errorf("%s is not a valid location", to)
+	}
+	if from == to {
+		return nil
+	}
+	cost, ok := r.worldMap.Cost(from, to)
+	if !ok {
+		return fmt.Errorf("there is no way from %s to %s", from, to)
+	}
+	if movement := r.def.Ranks[rank].Movement; cost > movement {
+		return fmt.Errorf("a(n) %s can't go from %s to %s, it costs %d movement points and it has %d", rank, from, to, cost, movement)
+	}
+	return nil
+}
+
+// CheckSpawn reports why unit can't join the units a player already has.
+func (r *Ruleset) CheckSpawn(units []Unit, unit Unit) error {
+	if !r.HasRank(unit.Rank) {
+		return fmt.Errorf("%s is not a valid unit", unit.Rank)
+	}
+	if !r.worldMap.HasRegion(unit.Location) {
+		return fmt.Errorf("%s is not a valid location", unit.Location)
+	}
+	limits := r.def.SpawnLimits
+	if limits.MaxUnits > 0 && len(units) >= limits.MaxUnits {
+		return fmt.Errorf("a player can't have more than %d units", limits.MaxUnits)
+	}
+	if limits.MaxPerRegion > 0 && len(unitsIn(Player{Units: unitMap(units)}, unit.Location)) >= limits.MaxPerRegion {
+		return fmt.Errorf("a player can't have more than %d units in %s", limits.MaxPerRegion, unit.Location)
+	}
+	return nil
+}
+
+func unitMap(units []Unit) map[int]Unit {
+	m := make(map[int]Unit, len(units))
+	for _, unit := range units {
+		m[unit.ID] = unit
+	}
+	return m
+}
+
+// Winner returns who won the game according to the win conditions, if
+// anybody did.
+func (r *Ruleset) Winner(players []Player) (string, bool) {
+	alive := []Player{}
+	for _, p := range players {
+		if len(p.Units) > 0 {
+			alive = append(alive, p)
+		}
+	}
+	if r.def.WinConditions.LastStanding && len(players) > 1 && len(alive) == 1 {
+		return alive[0].Username, true
+	}
+	if n := r.def.WinConditions.ControlRegions; n > 0 {
+		occupants := map[Location]map[string]bool{}
+		for _, p := range alive {
+			for _, unit := range p.Units {
+				if occupants[unit.Location] == nil {
+					occupants[unit.Location] = map[string]bool{}
+				}
+				occupants[unit.Location][p.Username] = true
+			}
+		}
+		controlled := map[string]int{}
+		for _, names := range occupants {
+			if len(names) == 1 {
+				for username := range names {
+					controlled[username]++
+				}
+			}
+		}
+		for _, p := range alive {
+			if controlled[p.Username] >= n {
+				return p.Username, true
+			}
+		}
+	}
+	return "", false
+}
+
+// PrintRules describes the map and the ranks of r.
+func PrintRules(r *Ruleset) {
+	fmt.Printf("Ruleset %s (%s)\n", r.def.Name, r.hash[:12])
+	fmt.Printf("Map %s:\n", r.worldMap.name)
+	for _, region := range r.worldMap.regions {
+		neighbours := []string{}
+		for next, cost := range r.worldMap.edges[region] {
+			neighbours = append(neighbours, fmt.Sprintf("%s (%d)", next, cost))
+		}
+		sort.Strings(neighbours)
+		fmt.Printf("* %s: %v\n", region, neighbours)
+	}
+	fmt.Println("Ranks:")
+	ranks := []string{}
+	for rank := range r.def.Ranks {
+		ranks = append(ranks, string(rank))
+	}
+	sort.Strings(ranks)
+	for _, rank := range ranks {
+		rules := r.def.Ranks[UnitRank(rank)]
+		fmt.Printf("* %s: power %d, %d movement points, costs %d\n", rank, rules.Power, rules.Movement, rules.Cost)
+	}
+	fmt.Printf("Wars are fought with %s.\n", r.def.Combat)
+	fmt.Printf("Yields every %v:\n", r.def.Economy.Tick)
+	for _, region := range r.worldMap.regions {
+		fmt.Printf("* %s: %d\n", region, r.def.Economy.Yields[region])
+	}
+}
@@ -5,30 +5,49 @@ import (
 	"fmt"
 )
 
-func (gs *GameState) CommandSpawn(words []string) error {
+func (gs *GameState) CommandSpawn(words []string) (SpawnEvent, error) {
 	if len(words) < 3 {
-		return errors.New("usage: spawn <location> <rank>")
+		return SpawnEvent{}, errors.New("usage: spawn <location> <rank>")
 	}
 
 	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
-		return fmt.Errorf("error: %s is not a valid location", locationName)
-	}
-
 	rank := words[2]
-	units := getAllRanks()
-	if _, ok := units[UnitRank(rank)]; !ok {
-		return fmt.Errorf("error: %s is not a valid unit", rank)
-	}
-
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
-		ID:       id,
+	unit := Unit{
+		Owner:    gs.GetUsername(),
 		Rank:     UnitRank(rank),
 		Location: Location(locationName),
-	})
+	}
+	if err := gs.rules.CheckSpawn(gs.getUnitsSnap(), unit); err != nil {
+		return SpawnEvent{}, fmt.Errorf("error: %w", err)
+	}
+	if err := gs.spend(unit.Rank); err != nil {
+		return SpawnEvent{}, fmt.Errorf("error: %w", err)
+	}
 
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
+	id := gs.unitIDs.Next()
+	unit.ID = id
+	gs.addUnit(unit)
+
+	fmt.Printf("Spawned a(n) %s in %s with id %v, %d left in the treasury\n", rank, locationName, id, gs.GetBalance())
+	return SpawnEvent{Username: gs.GetUsername(), Unit: unit}, nil
+}
+
+// spend takes the cost of a unit of rank from the treasury.
+func (gs *GameState) spend(rank UnitRank) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if err := gs.rules.CheckCost(gs.balance, rank); err != nil {
+		return err
+	}
+	gs.balance -= gs.rules.Cost(rank)
 	return nil
 }
+
+// HandleSpawn records a unit spawned by another player.
+func (gs *GameState) HandleSpawn(event SpawnEvent) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== Spawn Detected ====")
+	fmt.Printf("%s spawned a(n) %s in %s\n", event.Username, event.Unit.Rank, event.Unit.Location)
+	gs.updateOpponentUnit(event.Username, event.Unit)
+}
@@ -0,0 +1,233 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// TurnOrders are the spawns and moves a player queued during a turn, sent
+// to the server when the turn ends.
+type TurnOrders struct {
+	Username string
+	Turn     int
+	Spawns   []SpawnEvent
+	Moves    []ArmyMove
+}
+
+// RejectedOrder is a spawn or a move the world didn't allow.
+type RejectedOrder struct {
+	Username string
+	Err      error
+}
+
+// TurnResult is what happened when the orders of a turn were carried out.
+type TurnResult struct {
+	Battles  []Battle
+	Rejected []RejectedOrder
+}
+
+// ApplyTurn carries out the orders of every player at once. Players are
+// taken in the order of their usernames, and their orders in the order they
+// gave them: the spawns first, then the moves, which are all checked against
+// where the units were before any of them is made. A unit moves once per
+// turn. The wars are fought once every unit has moved, once in each
+// location units moved to, see contenders.
+func (w *World) ApplyTurn(orders []TurnOrders) TurnResult {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	orders = append([]TurnOrders(nil), orders...)
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].Username < orders[j].Username
+	})
+
+	result := TurnResult{Battles: []Battle{}, Rejected: []RejectedOrder{}}
+	reject := func(username string, err error) {
+		result.Rejected = append(result.Rejected, RejectedOrder{Username: username, Err: err})
+	}
+	for _, o := range orders {
+		for _, spawn := range o.Spawns {
+			if err := w.addUnit(o.Username, spawn.Unit); err != nil {
+				reject(o.Username, err)
+			}
+		}
+	}
+
+	moves := []ArmyMove{}
+	moved := map[string]map[int]bool{}
+	for _, o := range orders {
+		if len(o.Moves) == 0 {
+			continue
+		}
+		p, ok := w.players[o.Username]
+		if !ok {
+			reject(o.Username, fmt.Errorf("%s has no units", o.Username))
+			continue
+		}
+		if moved[o.Username] == nil {
+			moved[o.Username] = map[int]bool{}
+		}
+	moves:
+		for _, move := range o.Moves {
+			if move.Player.Username != o.Username {
+				reject(o.Username, fmt.Errorf("%s can't give orders to %s", o.Username, move.Player.Username))
+				continue
+			}
+			if err := checkMove(p, move, w.rules); err != nil {
+				reject(o.Username, err)
+				continue
+			}
+			for _, unit := range move.Units {
+				if moved[o.Username][unit.ID] {
+					reject(o.Username, fmt.Errorf("unit %v already moved this turn", unit.ID))
+					continue moves
+				}
+			}
+			for _, unit := range move.Units {
+				moved[o.Username][unit.ID] = true
+			}
+			moves = append(moves, move)
+		}
+	}
+
+	// arrived is the power each player moved to each location.
+	arrived := map[Location]map[string]int{}
+	for _, move := range moves {
+		username := move.Player.Username
+		p := w.players[username]
+		if arrived[move.ToLocation] == nil {
+			arrived[move.ToLocation] = map[string]int{}
+		}
+		for _, unit := range move.Units {
+			u := p.Units[unit.ID]
+			u.Location = move.ToLocation
+			p.Units[unit.ID] = u
+			arrived[move.ToLocation][username] += w.rules.Power([]Unit{u})
+		}
+	}
+	locations := make([]Location, 0, len(arrived))
+	for location := range arrived {
+		locations = append(locations, location)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	for _, location := range locations {
+		contenders := w.contenders(location, arrived[location])
+		if len(contenders) < 2 {
+			continue
+		}
+		result.Battles = append(result.Battles, w.fight(contenders[0], contenders[1:], location)...)
+	}
+	return result
+}
+
+// contenders returns the players with units in location, the attacker
+// first and the defenders in the order it fights them: the most power moved
+// there this turn first, then the most power there, then the lowest seed of
+// their units. Usernames only settle ties between identical armies.
+func (w *World) contenders(location Location, arrived map[string]int) []string {
+	type contender struct {
+		username string
+		arrived  int
+		power    int
+		seed     int64
+	}
+	contenders := []contender{}
+	for username, p := range w.players {
+		units := unitsIn(p, location)
+		if len(units) == 0 {
+			continue
+		}
+		contenders = append(contenders, contender{
+			username: username,
+			arrived:  arrived[username],
+			power:    w.rules.Power(units),
+			seed:     armySeed(location, units),
+		})
+	}
+	sort.Slice(contenders, func(i, j int) bool {
+		a, b := contenders[i], contenders[j]
+		switch {
+		case a.arrived != b.arrived:
+			return a.arrived > b.arrived
+		case a.power != b.power:
+			return a.power > b.power
+		case a.seed != b.seed:
+			return a.seed < b.seed
+		}
+		return a.username < b.username
+	})
+	usernames := make([]string, 0, len(contenders))
+	for _, c := range contenders {
+		usernames = append(usernames, c.username)
+	}
+	return usernames
+}
+
+// turnState is where a player is in a game played in turns.
+type turnState struct {
+	enabled bool
+	number  int
+	orders  TurnOrders
+}
+
+// HandleTurn follows the turns of the game. At the end of a turn, it returns
+// the orders queued since the previous one, if there are any.
+func (gs *GameState) HandleTurn(event routing.TurnEvent) (TurnOrders, bool) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.turn.enabled = true
+	gs.turn.number = event.Turn
+	switch event.Phase {
+	case routing.TurnStart:
+		fmt.Printf("==== Turn %d ====\n", event.Turn)
+		fmt.Printf("Give your orders before %s.\n", event.Ends.Format("15:04:05"))
+	case routing.TurnEnd:
+		fmt.Printf("==== End of Turn %d ====\n", event.Turn)
+		orders := gs.turn.orders
+		gs.turn.orders = TurnOrders{}
+		if len(orders.Spawns) == 0 && len(orders.Moves) == 0 {
+			fmt.Println("You gave no orders.")
+			return TurnOrders{}, false
+		}
+		fmt.Printf("Sending %d spawn(s) and %d move(s).\n", len(orders.Spawns), len(orders.Moves))
+		orders.Username = gs.Player.Username
+		orders.Turn = event.Turn
+		return orders, true
+	}
+	return TurnOrders{}, false
+}
+
+// InTurnMode tells whether the game is played in turns, in which case
+// spawns and moves are queued until the end of the turn.
+func (gs *GameState) InTurnMode() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.turn.enabled
+}
+
+func (gs *GameState) QueueSpawn(event SpawnEvent) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.turn.orders.Spawns = append(gs.turn.orders.Spawns, event)
+	fmt.Printf("Spawn queued for the end of turn %d\n", gs.turn.number)
+}
+
+func (gs *GameState) QueueMove(move ArmyMove) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.turn.orders.Moves = append(gs.turn.orders.Moves, move)
+	fmt.Printf("Move queued for the end of turn %d\n", gs.turn.number)
+}
+
+// getTurnSnap returns the current turn and the number of queued spawns and
+// moves.
+func (gs *GameState) getTurnSnap() (turn, spawns, moves int) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.turn.number, len(gs.turn.orders.Spawns), len(gs.turn.orders.Moves)
+}
@@ -0,0 +1,41 @@
+package gamelogic
+
+import "sync"
+
+// UnitIDs hands out the IDs of a player's units. On the server IDs only go
+// up, so the ID of a unit that died is never given to another one during a
+// game. A unit is identified across players by its owner and ID. Clients
+// follow the server, see Reset.
+type UnitIDs struct {
+	mu   sync.Mutex
+	next int
+}
+
+func NewUnitIDs() *UnitIDs {
+	return &UnitIDs{next: 1}
+}
+
+// Next allocates a new ID.
+func (a *UnitIDs) Next() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id := a.next
+	a.next++
+	return id
+}
+
+// Peek returns the ID Next will allocate.
+func (a *UnitIDs) Peek() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.next
+}
+
+// Reset makes next the ID Next allocates. Values below 1 are ignored.
+func (a *UnitIDs) Reset(next int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if next >= 1 {
+		a.next = next
+	}
+}
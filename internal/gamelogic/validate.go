@@ -0,0 +1,113 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MoveRateLimit is how many moves a player can make within Window.
+type MoveRateLimit struct {
+	Moves  int
+	Window time.Duration
+}
+
+// DefaultMoveRateLimit is far above what a player can type.
+var DefaultMoveRateLimit = MoveRateLimit{Moves: 10, Window: 10 * time.Second}
+
+// MoveValidator checks the moves received from other players before they
+// are trusted: the units must be the ones the sender is known to have, they
+// may only travel as far as the map allows and the sender must not move too
+// often.
+type MoveValidator struct {
+	rules *Ruleset
+
+	mu     sync.Mutex
+	limit  MoveRateLimit
+	recent map[string][]time.Time
+}
+
+func NewMoveValidator(rules *Ruleset, limit MoveRateLimit) *MoveValidator {
+	return &MoveValidator{rules: rules, limit: limit, recent: map[string][]time.Time{}}
+}
+
+// Validate checks move against roster, the units its sender is known to
+// have. Every move counts towards the rate limit, valid or not.
+func (v *MoveValidator) Validate(roster Player, move ArmyMove) error {
+	if err := v.allow(move.Player.Username); err != nil {
+		return err
+	}
+	return checkMove(roster, move, v.rules)
+}
+
+func (v *MoveValidator) allow(username string) error {
+	if v.limit.Moves <= 0 {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	recent := v.recent[username][:0]
+	for _, t := range v.recent[username] {
+		if now.Sub(t) < v.limit.Window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	v.recent[username] = recent
+	if len(recent) > v.limit.Moves {
+		return fmt.Errorf("%s made more than %d moves in %v", username, v.limit.Moves, v.limit.Window)
+	}
+	return nil
+}
+
+// checkMove verifies that the units of move, and the snapshot of its
+// player, match roster.
+func checkMove(roster Player, move ArmyMove, rules *Ruleset) error {
+	username := move.Player.Username
+	if username == "" {
+		return fmt.Errorf("the move has no player")
+	}
+	if !rules.Map().HasRegion(move.ToLocation) {
+		return fmt.Errorf("%s is not a valid location", move.ToLocation)
+	}
+	if len(move.Units) == 0 {
+		return fmt.Errorf("%s moved no units", username)
+	}
+
+	moved := map[int]bool{}
+	for _, claimed := range move.Units {
+		if moved[claimed.ID] {
+			return fmt.Errorf("%s moved unit %v twice", username, claimed.ID)
+		}
+		moved[claimed.ID] = true
+		unit, err := checkUnit(roster, username, claimed)
+		if err != nil {
+			return err
+		}
+		if err := rules.CheckMove(unit.Rank, unit.Location, move.ToLocation); err != nil {
+			return fmt.Errorf("unit %v of %s: %w", unit.ID, username, err)
+		}
+	}
+	for _, claimed := range move.Player.Units {
+		if _, err := checkUnit(roster, username, claimed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUnit returns the unit of roster claimed is supposed to be.
+func checkUnit(roster Player, username string, claimed Unit) (Unit, error) {
+	unit, ok := roster.Units[claimed.ID]
+	if !ok {
+		return Unit{}, fmt.Errorf("%s has no unit with ID %v", username, claimed.ID)
+	}
+	if claimed.Owner != username {
+		return Unit{}, fmt.Errorf("unit %v of %s is owned by %q", claimed.ID, username, claimed.Owner)
+	}
+	if claimed.Rank != unit.Rank {
+		return Unit{}, fmt.Errorf("unit %v of %s is a(n) %s, not a(n) %s", claimed.ID, username, unit.Rank, claimed.Rank)
+	}
+	return unit, nil
+}
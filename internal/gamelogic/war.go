@@ -14,6 +14,9 @@ const (
 	WarOutcomeDraw
 )
 
+// HandleWar shows the attacker how the war should end. The server resolves
+// it and sends the casualties, which HandleCasualties removes, so nothing is
+// removed here.
 func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner string, loser string) {
 	defer fmt.Println("------------------------")
 	fmt.Println()
@@ -59,47 +62,57 @@ func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner
 	for _, unit := range defenderUnits {
 		fmt.Printf("  * %v\n", unit.Rank)
 	}
-	attackerPower := unitsToPowerLevel(attackerUnits)
-	defenderPower := unitsToPowerLevel(defenderUnits)
-	fmt.Printf("Attacker has a power level of %v\n", attackerPower)
-	fmt.Printf("Defender has a power level of %v\n", defenderPower)
-	if attackerPower > defenderPower {
+	result := gs.rules.Resolver().Resolve(attackerUnits, defenderUnits, rw.Seed)
+	fmt.Printf("Attacker has a power level of %v\n", result.AttackerPower)
+	fmt.Printf("Defender has a power level of %v\n", result.DefenderPower)
+	defer fmt.Printf("%d of your units in %s should be killed and %d survive, the server has the last word.\n", len(result.AttackerCasualties), overlappingLocation, len(result.AttackerSurvivors))
+	switch result.Outcome {
+	case WarOutcomeYouWon:
 		fmt.Printf("%s has won the war!\n", rw.Attacker.Username)
-		if player.Username == rw.Defender.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Attacker.Username, rw.Defender.Username
-		}
 		return WarOutcomeYouWon, rw.Attacker.Username, rw.Defender.Username
-	} else if defenderPower > attackerPower {
+	case WarOutcomeOpponentWon:
 		fmt.Printf("%s has won the war!\n", rw.Defender.Username)
-		if player.Username == rw.Attacker.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
-		}
-		return WarOutcomeYouWon, rw.Defender.Username, rw.Attacker.Username
+		fmt.Println("You have lost the war!")
+		return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
 	}
 	fmt.Println("The war ended in a draw!")
-	fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-	gs.removeUnitsInLocation(overlappingLocation)
 	return WarOutcomeDraw, rw.Attacker.Username, rw.Defender.Username
 }
 
-func unitsToPowerLevel(units []Unit) int {
-	power := 0
-	for _, unit := range units {
-		if unit.Rank == RankArtillery {
-			power += 10
-		}
-		if unit.Rank == RankCavalry {
-			power += 5
+// HandleCasualties removes the units killed in a war the server resolved,
+// the player's own and the ones they knew of their opponents.
+func (gs *GameState) HandleCasualties(battle Battle) {
+	username := gs.GetUsername()
+	dead := map[string][]Unit{}
+	for _, unit := range battle.Casualties {
+		dead[unit.Owner] = append(dead[unit.Owner], unit)
+	}
+	gs.removeUnits(dead[username])
+	for owner, units := range dead {
+		if owner != username {
+			gs.removeOpponentUnits(owner, units)
 		}
-		if unit.Rank == RankInfantry {
-			power += 1
+	}
+	if username != battle.Attacker && username != battle.Defender {
+		return
+	}
+
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== Casualty Report ====")
+	fmt.Printf("The war between %s and %s in %s is over.\n", battle.Attacker, battle.Defender, battle.Location)
+	for _, owner := range []string{battle.Attacker, battle.Defender} {
+		fmt.Printf("%s lost %d unit(s).\n", owner, len(dead[owner]))
+		for _, unit := range dead[owner] {
+			fmt.Printf("  * %v: %v\n", unit.ID, unit.Rank)
 		}
 	}
-	return power
+}
+
+// NewRecognitionOfWar declares the war attacker starts by moving into a
+// location where defender has units, with the seed of that war.
+func NewRecognitionOfWar(attacker, defender Player) RecognitionOfWar {
+	location := getOverlappingLocation(attacker, defender)
+	seed := WarSeed(location, unitsIn(attacker, location), unitsIn(defender, location))
+	return RecognitionOfWar{Attacker: attacker, Defender: defender, Seed: seed}
 }
@@ -0,0 +1,37 @@
+package gamelogic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHandleCasualtiesRemovesTheReportedUnits(t *testing.T) {
+	gs := NewGameState("alice", testRuleset(t, CombatPower))
+	for _, unit := range army("alice", 1, RankInfantry, RankCavalry, RankInfantry, RankArtillery) {
+		gs.Player.Units[unit.ID] = unit
+	}
+	bob := Player{Username: "bob", Units: map[int]Unit{}}
+	for _, unit := range army("bob", 1, RankInfantry, RankInfantry) {
+		bob.Units[unit.ID] = unit
+	}
+	gs.Opponents["bob"] = bob
+
+	gs.HandleCasualties(Battle{
+		Location: "europe",
+		Attacker: "alice",
+		Defender: "bob",
+		Outcome:  WarOutcomeYouWon,
+		Casualties: []Unit{
+			gs.Player.Units[1],
+			gs.Player.Units[3],
+			bob.Units[2],
+		},
+	})
+
+	if got, want := idsOf(unitSlice(gs.GetPlayerSnap())), []int{2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice has units %v, want %v", got, want)
+	}
+	if got, want := idsOf(unitSlice(gs.Opponents["bob"])), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bob has units %v, want %v", got, want)
+	}
+}
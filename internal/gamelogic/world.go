@@ -0,0 +1,297 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// PlayerState is the server's authoritative view of a player's units, sent
+// to the player whenever it changes. Reason explains a correction.
+type PlayerState struct {
+	Player Player
+	Reason string
+	// NextUnitID is the ID the player must give their next unit.
+	NextUnitID int
+	// Opponents are the units of the other players, which moves received
+	// from them are validated against.
+	Opponents []Player
+	Treasury  Treasury
+	// RulesetHash identifies the rules of the server, clients playing by
+	// other rules must leave.
+	RulesetHash string
+	// Turn is the start of the turn being played, nil when the game is
+	// played in real time.
+	Turn *routing.TurnEvent `json:",omitempty"`
+}
+
+// Battle is a war the server resolved while applying a move. It is sent to
+// the players as a casualty report, see HandleCasualties.
+type Battle struct {
+	Location Location
+	Attacker string
+	Defender string
+	Outcome  WarOutcome // from the attacker's point of view
+	// Casualties are the units of both sides killed in the war.
+	Casualties []Unit
+}
+
+// World is the server's model of every player's units. Clients report
+// their moves, the world decides where the units actually are.
+type World struct {
+	rules    *Ruleset
+	mu       sync.RWMutex
+	players  map[string]Player
+	unitIDs  map[string]*UnitIDs
+	balances map[string]int
+}
+
+func NewWorld(rules *Ruleset) *World {
+	return &World{
+		rules:    rules,
+		players:  map[string]Player{},
+		unitIDs:  map[string]*UnitIDs{},
+		balances: map[string]int{},
+	}
+}
+
+func (w *World) Rules() *Ruleset {
+	return w.rules
+}
+
+// Winner returns who won the game, if anybody did yet.
+func (w *World) Winner() (string, bool) {
+	return w.rules.Winner(w.Players())
+}
+
+func (w *World) player(username string) Player {
+	p, ok := w.players[username]
+	if !ok {
+		p = Player{Username: username, Units: map[int]Unit{}}
+		w.players[username] = p
+		w.unitIDs[username] = NewUnitIDs()
+		w.balances[username] = w.rules.StartingBalance()
+	}
+	return p
+}
+
+// State returns the units of username along with the next unit ID they may
+// use and their treasury.
+func (w *World) State(username, reason string) PlayerState {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	state := PlayerState{
+		Player:      Player{Username: username, Units: map[int]Unit{}},
+		Reason:      reason,
+		NextUnitID:  1,
+		Opponents:   []Player{},
+		Treasury:    Treasury{Balance: w.rules.StartingBalance()},
+		RulesetHash: w.rules.Hash(),
+	}
+	if p, ok := w.players[username]; ok {
+		state.Player = copyPlayer(p)
+		state.NextUnitID = w.unitIDs[username].Peek()
+		state.Treasury = Treasury{Balance: w.balances[username], Income: w.rules.Income(unitSlice(p))}
+	}
+	for other, p := range w.players {
+		if other != username {
+			state.Opponents = append(state.Opponents, copyPlayer(p))
+		}
+	}
+	return state
+}
+
+// Player returns a copy of the units of username.
+func (w *World) Player(username string) (Player, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p, ok := w.players[username]
+	if !ok {
+		return Player{}, false
+	}
+	return copyPlayer(p), true
+}
+
+// Players returns a copy of every player, sorted by username.
+func (w *World) Players() []Player {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	players := make([]Player, 0, len(w.players))
+	for _, p := range w.players {
+		players = append(players, copyPlayer(p))
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Username < players[j].Username
+	})
+	return players
+}
+
+func unitSlice(p Player) []Unit {
+	units := make([]Unit, 0, len(p.Units))
+	for _, unit := range p.Units {
+		units = append(units, unit)
+	}
+	return units
+}
+
+func copyPlayer(p Player) Player {
+	units := make(map[int]Unit, len(p.Units))
+	for id, unit := range p.Units {
+		units[id] = unit
+	}
+	return Player{Username: p.Username, Units: units}
+}
+
+// AddUnit places a new unit of username in the world, paid for from their
+// treasury. The unit must have the next ID of username, see State.
+func (w *World) AddUnit(username string, unit Unit) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.addUnit(username, unit)
+}
+
+func (w *World) addUnit(username string, unit Unit) error {
+	if unit.Owner != username {
+		return fmt.Errorf("unit %v of %s is owned by %q", unit.ID, username, unit.Owner)
+	}
+	p := w.player(username)
+	if err := w.rules.CheckSpawn(unitSlice(p), unit); err != nil {
+		return err
+	}
+	if err := w.rules.CheckCost(w.balances[username], unit.Rank); err != nil {
+		return err
+	}
+	ids := w.unitIDs[username]
+	if unit.ID < 1 {
+		return fmt.Errorf("invalid unit ID %v", unit.ID)
+	}
+	if _, ok := p.Units[unit.ID]; ok {
+		return fmt.Errorf("%s already has a unit with the ID %v", username, unit.ID)
+	}
+	if next := ids.Peek(); unit.ID != next {
+		return fmt.Errorf("the next unit ID of %s is %v, not %v", username, next, unit.ID)
+	}
+	ids.Next()
+	w.balances[username] -= w.rules.Cost(unit.Rank)
+	p.Units[unit.ID] = unit
+	return nil
+}
+
+// ApplyMove checks move against the world and moves the units, which must
+// have been spawned before and be able to reach the destination. The move is
+// validated once, with moves, against the units the world holds. Wars caused
+// by the move are fought right away.
+func (w *World) ApplyMove(move ArmyMove, moves *MoveValidator) ([]Battle, error) {
+	username := move.Player.Username
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p, ok := w.players[username]
+	if !ok {
+		return nil, fmt.Errorf("%s has no units", username)
+	}
+	if err := moves.Validate(p, move); err != nil {
+		return nil, err
+	}
+	for _, moved := range move.Units {
+		unit := p.Units[moved.ID]
+		unit.Location = move.ToLocation
+		p.Units[moved.ID] = unit
+	}
+	return w.fight(username, w.others(username), move.ToLocation), nil
+}
+
+// others returns the usernames of the players other than username, sorted.
+func (w *World) others(username string) []string {
+	usernames := make([]string, 0, len(w.players))
+	for other := range w.players {
+		if other != username {
+			usernames = append(usernames, other)
+		}
+	}
+	sort.Strings(usernames)
+	return usernames
+}
+
+// fight resolves the wars between username and each of defenders with units
+// in location, in order, with the same resolver and seed as HandleWar.
+func (w *World) fight(username string, defenders []string, location Location) []Battle {
+	battles := []Battle{}
+	for _, other := range defenders {
+		attackerUnits := unitsIn(w.players[username], location)
+		defenderUnits := unitsIn(w.players[other], location)
+		if len(attackerUnits) == 0 {
+			break
+		}
+		if len(defenderUnits) == 0 {
+			continue
+		}
+
+		seed := WarSeed(location, attackerUnits, defenderUnits)
+		result := w.rules.Resolver().Resolve(attackerUnits, defenderUnits, seed)
+		w.removeUnits(username, result.AttackerCasualties)
+		w.removeUnits(other, result.DefenderCasualties)
+		battles = append(battles, Battle{
+			Location:   location,
+			Attacker:   username,
+			Defender:   other,
+			Outcome:    result.Outcome,
+			Casualties: result.Casualties(),
+		})
+	}
+	return battles
+}
+
+func unitsIn(p Player, location Location) []Unit {
+	units := []Unit{}
+	for _, unit := range p.Units {
+		if unit.Location == location {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+func (w *World) removeUnits(username string, units []Unit) {
+	p := w.players[username]
+	for _, unit := range units {
+		delete(p.Units, unit.ID)
+	}
+}
+
+// HandlePlayerState replaces the units of the player with the ones the
+// server knows about.
+func (gs *GameState) HandlePlayerState(state PlayerState) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== World Update ====")
+	if state.Reason != "" {
+		fmt.Printf("The server corrected your units: %s\n", state.Reason)
+	}
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	units := map[int]Unit{}
+	for id, unit := range state.Player.Units {
+		units[id] = unit
+	}
+	// The server takes the units in the order of their IDs, so the IDs
+	// given to spawns it rejected are handed out again.
+	gs.unitIDs.Reset(state.NextUnitID)
+	gs.Player.Units = units
+	gs.balance = state.Treasury.Balance
+	for _, opponent := range state.Opponents {
+		gs.Opponents[opponent.Username] = opponent
+	}
+	// Players who join during a turn don't wait for the next one to give
+	// their orders.
+	if state.Turn != nil {
+		gs.turn.enabled = true
+		if state.Turn.Turn > gs.turn.number {
+			gs.turn.number = state.Turn.Turn
+			fmt.Printf("The game is played in turns, give your orders for turn %d before %s.\n", state.Turn.Turn, state.Turn.Ends.Format("15:04:05"))
+		}
+	}
+	fmt.Printf("You have %d units.\n", len(units))
+}
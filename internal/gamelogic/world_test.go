@@ -0,0 +1,97 @@
+package gamelogic
+
+import (
+	"sort"
+	"testing"
+)
+
+func idsOf(units []Unit) []int {
+	ids := make([]int, 0, len(units))
+	for _, unit := range units {
+		ids = append(ids, unit.ID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// checkPartition fails unless casualties and survivors together are units,
+// with no unit in both.
+func checkPartition(t *testing.T, side string, units, casualties, survivors []Unit) {
+	t.Helper()
+	seen := map[int]int{}
+	for _, unit := range casualties {
+		seen[unit.ID]++
+	}
+	for _, unit := range survivors {
+		seen[unit.ID]++
+	}
+	for _, unit := range units {
+		if seen[unit.ID] != 1 {
+			t.Errorf("%s unit %d is counted %d times", side, unit.ID, seen[unit.ID])
+		}
+		delete(seen, unit.ID)
+	}
+	for id := range seen {
+		t.Errorf("%s unit %d didn't fight", side, id)
+	}
+}
+
+func TestResolvePartitionsUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		attacker []Unit
+		defender []Unit
+	}{
+		{"attacker wins", army("alice", 1, RankArtillery, RankInfantry), army("bob", 1, RankCavalry)},
+		{"defender wins", army("alice", 1, RankInfantry), army("bob", 1, RankArtillery, RankCavalry)},
+		{"draw", army("alice", 1, RankCavalry, RankCavalry), army("bob", 1, RankArtillery)},
+		{"crowd", army("alice", 1, RankInfantry, RankInfantry, RankInfantry, RankCavalry), army("bob", 10, RankInfantry, RankCavalry, RankInfantry)},
+	}
+	for _, combat := range []string{CombatPower, CombatDice} {
+		resolver := testRuleset(t, combat).Resolver()
+		for _, tt := range tests {
+			t.Run(combat+"/"+tt.name, func(t *testing.T) {
+				result := resolver.Resolve(tt.attacker, tt.defender, WarSeed("europe", tt.attacker, tt.defender))
+				checkPartition(t, "attacker", tt.attacker, result.AttackerCasualties, result.AttackerSurvivors)
+				checkPartition(t, "defender", tt.defender, result.DefenderCasualties, result.DefenderSurvivors)
+			})
+		}
+	}
+}
+
+func TestFightRemovesExactlyTheCasualties(t *testing.T) {
+	for _, combat := range []string{CombatPower, CombatDice} {
+		t.Run(combat, func(t *testing.T) {
+			w := NewWorld(testRuleset(t, combat))
+			sides := map[string][]Unit{
+				"alice": army("alice", 1, RankCavalry, RankInfantry, RankInfantry),
+				"bob":   army("bob", 1, RankInfantry, RankCavalry, RankInfantry),
+			}
+			for username, units := range sides {
+				p := Player{Username: username, Units: map[int]Unit{}}
+				for _, unit := range units {
+					p.Units[unit.ID] = unit
+				}
+				// A unit away from the war must be left alone.
+				p.Units[100] = Unit{ID: 100, Owner: username, Rank: RankInfantry, Location: "asia"}
+				w.players[username] = p
+			}
+
+			battles := w.fight("alice", []string{"bob"}, "europe")
+			if len(battles) != 1 {
+				t.Fatalf("fought %d wars, want 1", len(battles))
+			}
+			dead := map[string][]Unit{}
+			for _, unit := range battles[0].Casualties {
+				dead[unit.Owner] = append(dead[unit.Owner], unit)
+			}
+			for username, units := range sides {
+				survivors := unitsIn(w.players[username], "europe")
+				checkPartition(t, username, units, dead[username], survivors)
+				if _, ok := w.players[username].Units[100]; !ok {
+					t.Errorf("%s lost the unit in asia", username)
+				}
+			}
+		})
+	}
+}
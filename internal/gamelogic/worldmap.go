@@ -0,0 +1,169 @@
+package gamelogic
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Edge links two regions of a map both ways. Crossing it costs Cost
+// movement points.
+type Edge struct {
+	From Location `json:"from" yaml:"from"`
+	To   Location `json:"to" yaml:"to"`
+	Cost int      `json:"cost" yaml:"cost"`
+}
+
+// MapDefinition is the format of map files, e.g.
+//
+//	{
+//	  "name": "islands",
+//	  "regions": ["north", "south"],
+//	  "edges": [{"from": "north", "to": "south", "cost": 2}]
+//	}
+type MapDefinition struct {
+	Name    string     `json:"name" yaml:"name"`
+	Regions []Location `json:"regions" yaml:"regions"`
+	Edges   []Edge     `json:"edges" yaml:"edges"`
+}
+
+// Map is the graph of regions units move across. Each move, a unit can
+// travel as far as the movement points of its rank allow, see Ruleset.
+type Map struct {
+	name    string
+	regions []Location
+	edges   map[Location]map[Location]int
+}
+
+// defaultMapDefinition links the continents by land, which costs 1, or by
+// sea, which costs 2.
+var defaultMapDefinition = MapDefinition{
+	Name:    "continents",
+	Regions: []Location{"americas", "europe", "africa", "asia", "australia", "antarctica"},
+	Edges: []Edge{
+		{From: "europe", To: "asia", Cost: 1},
+		{From: "europe", To: "africa", Cost: 1},
+		{From: "africa", To: "asia", Cost: 1},
+		{From: "asia", To: "australia", Cost: 2},
+		{From: "americas", To: "europe", Cost: 2},
+		{From: "americas", To: "africa", Cost: 2},
+		{From: "americas", To: "asia", Cost: 2},
+		{From: "americas", To: "antarctica", Cost: 2},
+		{From: "africa", To: "antarctica", Cost: 2},
+		{From: "australia", To: "antarctica", Cost: 2},
+	},
+}
+
+// LoadMapDefinition reads a JSON map file, which NewMap validates.
+func LoadMapDefinition(path string) (MapDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MapDefinition{}, fmt.Errorf("could not read map file: %w", err)
+	}
+	var def MapDefinition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return MapDefinition{}, fmt.Errorf("could not parse map file %s: %w", path, err)
+	}
+	return def, nil
+}
+
+// NewMap checks def: regions must be unique, edges must link two of them
+// at a positive cost and every region must be reachable.
+func NewMap(def MapDefinition) (*Map, error) {
+	if len(def.Regions) == 0 {
+		return nil, errors.New("the map has no regions")
+	}
+	m := &Map{name: def.Name, edges: map[Location]map[Location]int{}}
+	for _, region := range def.Regions {
+		if region == "" {
+			return nil, errors.New("regions need a name")
+		}
+		if _, ok := m.edges[region]; ok {
+			return nil, fmt.Errorf("region %s is defined twice", region)
+		}
+		m.edges[region] = map[Location]int{}
+		m.regions = append(m.regions, region)
+	}
+	for _, edge := range def.Edges {
+		if !m.HasRegion(edge.From) || !m.HasRegion(edge.To) {
+			return nil, fmt.Errorf("edge %s-%s links an unknown region", edge.From, edge.To)
+		}
+		if edge.From == edge.To {
+			return nil, fmt.Errorf("edge %s-%s is a loop", edge.From, edge.To)
+		}
+		if edge.Cost < 1 {
+			return nil, fmt.Errorf("edge %s-%s must cost at least 1", edge.From, edge.To)
+		}
+		m.edges[edge.From][edge.To] = edge.Cost
+		m.edges[edge.To][edge.From] = edge.Cost
+	}
+	costs := m.costsFrom(m.regions[0])
+	for _, region := range m.regions {
+		if _, ok := costs[region]; !ok {
+			return nil, fmt.Errorf("region %s can't be reached from %s", region, m.regions[0])
+		}
+	}
+	return m, nil
+}
+
+func (m *Map) Name() string {
+	return m.name
+}
+
+// Regions returns the regions in the order of the definition.
+func (m *Map) Regions() []Location {
+	return append([]Location{}, m.regions...)
+}
+
+func (m *Map) HasRegion(region Location) bool {
+	_, ok := m.edges[region]
+	return ok
+}
+
+// Cost returns the cost of the cheapest path between two regions.
+func (m *Map) Cost(from, to Location) (int, bool) {
+	cost, ok := m.costsFrom(from)[to]
+	return cost, ok
+}
+
+// costsFrom runs Dijkstra's algorithm from start.
+func (m *Map) costsFrom(start Location) map[Location]int {
+	costs := map[Location]int{}
+	if !m.HasRegion(start) {
+		return costs
+	}
+	queue := &pathQueue{{region: start}}
+	for queue.Len() > 0 {
+		p := heap.Pop(queue).(path)
+		if _, done := costs[p.region]; done {
+			continue
+		}
+		costs[p.region] = p.cost
+		for next, cost := range m.edges[p.region] {
+			if _, done := costs[next]; !done {
+				heap.Push(queue, path{region: next, cost: p.cost + cost})
+			}
+		}
+	}
+	return costs
+}
+
+type path struct {
+	region Location
+	cost   int
+}
+
+type pathQueue []path
+
+func (q pathQueue) Len() int           { return len(q) }
+func (q pathQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q pathQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x any)        { *q = append(*q, x.(path)) }
+func (q *pathQueue) Pop() any {
+	old := *q
+	p := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return p
+}
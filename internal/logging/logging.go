@@ -0,0 +1,91 @@
+// Package logging sets up the operational logs of the Peril server and
+// client with log/slog.
+//
+// Operational logs go to standard error or a file, while the game narrative
+// (moves, wars, command output) stays on standard output.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Keys of the fields shared by the operational logs.
+const (
+	KeyQueue      = "queue"
+	KeyRoutingKey = "routing_key"
+	KeyExchange   = "exchange"
+	KeyUsername   = "username"
+	KeyMessageID  = "message_id"
+	KeyOutcome    = "outcome"
+	KeyError      = "error"
+)
+
+// Config selects the level, format and destination of the logs. Output is
+// a file, standard error when empty.
+type Config struct {
+	Level  string
+	Format string
+	Output string
+}
+
+// ParseLevel accepts debug, info, warn and error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level: %s", s)
+	}
+	return level, nil
+}
+
+// Setup makes the configured logger the default one, which the log package
+// also writes to. The returned function closes the output file.
+func Setup(cfg Config) (func() error, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = os.Stderr
+	closeOutput := func() error { return nil }
+	if cfg.Output != "" {
+		f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open log output: %w", err)
+		}
+		w, closeOutput = f, f.Close
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		closeOutput()
+		return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return closeOutput, nil
+}
+
+// Err is the field of a failure.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// Fatal logs msg as an error and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
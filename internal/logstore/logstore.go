@@ -0,0 +1,191 @@
+package logstore
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS game_logs (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	time     INTEGER NOT NULL,
+	username TEXT    NOT NULL,
+	message  TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS game_logs_username_time ON game_logs (username, time);
+CREATE INDEX IF NOT EXISTS game_logs_time ON game_logs (time);
+`
+
+const DefaultPageSize = 20
+
+// Store keeps game logs in an embedded SQLite database. It implements
+// gamelogic.LogSink.
+type Store struct {
+	db *sql.DB
+}
+
+func Open(path string) (*Store, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open logs database: %v", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create logs schema: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) WriteLogs(logs []routing.GameLog) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin logs transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO game_logs (time, username, message) VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("could not prepare logs insert: %v", err)
+	}
+	defer stmt.Close()
+	for _, gamelog := range logs {
+		if _, err := stmt.Exec(gamelog.CurrentTime.UnixNano(), gamelog.Username, gamelog.Message); err != nil {
+			return fmt.Errorf("could not insert game log: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit game logs: %v", err)
+	}
+	return nil
+}
+
+// Sync is a no-op: committed batches are already durable.
+func (s *Store) Sync() error {
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Query filters game logs. Zero values match everything; Page starts at 1.
+type Query struct {
+	Username string
+	Since    time.Time
+	Contains string
+	Page     int
+	PageSize int
+}
+
+// ParseQuery reads `key=value` filters: user, since (a duration such as
+// 10m), contains, page and limit.
+func ParseQuery(args []string) (Query, error) {
+	q := Query{Page: 1, PageSize: DefaultPageSize}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return Query{}, fmt.Errorf("error: %s is not a key=value filter", arg)
+		}
+		switch key {
+		case "user":
+			q.Username = value
+		case "since":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return Query{}, fmt.Errorf("error: %s is not a valid duration", value)
+			}
+			q.Since = time.Now().Add(-d)
+		case "contains":
+			q.Contains = value
+		case "page":
+			page, err := strconv.Atoi(value)
+			if err != nil || page < 1 {
+				return Query{}, fmt.Errorf("error: %s is not a valid page", value)
+			}
+			q.Page = page
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				return Query{}, fmt.Errorf("error: %s is not a valid limit", value)
+			}
+			q.PageSize = limit
+		default:
+			return Query{}, fmt.Errorf("error: unknown filter %s", key)
+		}
+	}
+	return q, nil
+}
+
+// Result is a page of game logs, newest first.
+type Result struct {
+	Logs  []routing.GameLog
+	Total int
+	Page  int
+	Pages int
+}
+
+func (s *Store) Query(q Query) (Result, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = DefaultPageSize
+	}
+
+	conditions := []string{}
+	args := []any{}
+	if q.Username != "" {
+		conditions = append(conditions, "username = ?")
+		args = append(args, q.Username)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "time >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if q.Contains != "" {
+		conditions = append(conditions, `message LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.Contains)+"%")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	result := Result{Page: q.Page}
+	err := s.db.QueryRow("SELECT COUNT(*) FROM game_logs"+where, args...).Scan(&result.Total)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not count game logs: %v", err)
+	}
+	result.Pages = (result.Total + q.PageSize - 1) / q.PageSize
+
+	rows, err := s.db.Query("SELECT time, username, message FROM game_logs"+where+" ORDER BY time DESC, id DESC LIMIT ? OFFSET ?",
+		append(args, q.PageSize, (q.Page-1)*q.PageSize)...)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not query game logs: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var nanos int64
+		var gamelog routing.GameLog
+		if err := rows.Scan(&nanos, &gamelog.Username, &gamelog.Message); err != nil {
+			return Result{}, fmt.Errorf("could not read game log: %v", err)
+		}
+		gamelog.CurrentTime = time.Unix(0, nanos)
+		result.Logs = append(result.Logs, gamelog)
+	}
+	if err := rows.Err(); err != nil {
+		return Result{}, fmt.Errorf("could not read game logs: %v", err)
+	}
+	return result, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,55 @@
+package pubsub
+
+import (
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// InspectQueue reads the message and consumer counts of an existing queue.
+// It uses a channel of its own since a missing queue closes the channel.
+// The exclusive queues of other connections can't be read this way, see
+// ManagementAPI.
+func InspectQueue(conn *amqp.Connection, name string) (amqp.Queue, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("error during channel creation: %w", err)
+	}
+	defer channel.Close()
+
+	queue, err := channel.QueueDeclarePassive(name, false, false, false, false, nil)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("error while inspecting queue %s: %w", name, err)
+	}
+	return queue, nil
+}
+
+// InspectQueues reads several queues like InspectQueue, on a single channel
+// which is only reopened once a queue couldn't be read.
+func InspectQueues(conn *amqp.Connection, names []string) (map[string]amqp.Queue, map[string]error) {
+	queues := map[string]amqp.Queue{}
+	errs := map[string]error{}
+	var channel *amqp.Channel
+	defer func() {
+		if channel != nil {
+			channel.Close()
+		}
+	}()
+	for _, name := range names {
+		if channel == nil || channel.IsClosed() {
+			var err error
+			if channel, err = conn.Channel(); err != nil {
+				channel = nil
+				errs[name] = fmt.Errorf("error during channel creation: %w", err)
+				continue
+			}
+		}
+		queue, err := channel.QueueDeclarePassive(name, false, false, false, false, nil)
+		if err != nil {
+			errs[name] = fmt.Errorf("error while inspecting queue %s: %w", name, err)
+			continue
+		}
+		queues[name] = queue
+	}
+	return queues, errs
+}
@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ManagementAPI reads queues through the HTTP API of the RabbitMQ
+// management plugin, which also sees the exclusive queues of other
+// connections.
+type ManagementAPI struct {
+	url      string
+	username string
+	password string
+	vhost    string
+	client   *http.Client
+}
+
+// NewManagementAPI reaches the API at baseURL with the credentials and the
+// virtual host of brokerURL, or vhost when it isn't empty.
+func NewManagementAPI(baseURL, brokerURL, vhost string) (*ManagementAPI, error) {
+	uri, err := amqp.ParseURI(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker URL: %w", err)
+	}
+	if vhost == "" {
+		vhost = uri.Vhost
+	}
+	return &ManagementAPI{
+		url:      strings.TrimSuffix(baseURL, "/"),
+		username: uri.Username,
+		password: uri.Password,
+		vhost:    vhost,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// InspectQueue reads the ready messages and the consumers of a queue, like
+// the passive declare of the package function.
+func (m *ManagementAPI) InspectQueue(ctx context.Context, name string) (amqp.Queue, error) {
+	endpoint := fmt.Sprintf("%s/api/queues/%s/%s", m.url, url.PathEscape(m.vhost), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("error while inspecting queue %s: %w", name, err)
+	}
+	req.SetBasicAuth(m.username, m.password)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("error while inspecting queue %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return amqp.Queue{}, fmt.Errorf("error while inspecting queue %s: management API answered %s", name, resp.Status)
+	}
+
+	var body struct {
+		MessagesReady int `json:"messages_ready"`
+		Consumers     int `json:"consumers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return amqp.Queue{}, fmt.Errorf("error while decoding queue %s: %w", name, err)
+	}
+	return amqp.Queue{Name: name, Messages: body.MessagesReady, Consumers: body.Consumers}, nil
+}
@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	publishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "published_total",
+		Help:      "Messages published.",
+	}, []string{"exchange", "key"})
+	confirmedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "confirmed_total",
+		Help:      "Published messages confirmed by the broker.",
+	}, []string{"exchange", "key"})
+	returnedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "returned_total",
+		Help:      "Mandatory published messages returned as unroutable.",
+	}, []string{"exchange", "key"})
+
+	deliveredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "delivered_total",
+		Help:      "Messages delivered to subscribers.",
+	}, []string{"queue", "key"})
+	ackedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "acked_total",
+		Help:      "Deliveries acknowledged.",
+	}, []string{"queue", "key"})
+	nackedRequeueTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "nacked_requeue_total",
+		Help:      "Deliveries rejected and requeued.",
+	}, []string{"queue", "key"})
+	nackedDiscardTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "nacked_discard_total",
+		Help:      "Deliveries rejected without requeueing, including filtered ones.",
+	}, []string{"queue", "key"})
+	decodeErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "decode_errors_total",
+		Help:      "Deliveries whose body couldn't be decoded.",
+	}, []string{"queue", "key"})
+	handlerSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "peril",
+		Subsystem: "pubsub",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent in subscription handlers.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 10),
+	}, []string{"queue", "key"})
+)
+
+// keyPattern turns a routing key such as army_moves.alice into the pattern
+// army_moves.* so per-player keys don't explode the metrics cardinality.
+func keyPattern(key string) string {
+	prefix, _, found := strings.Cut(key, ".")
+	if !found {
+		return key
+	}
+	return prefix + ".*"
+}
+
+// subscriptionMetrics are the metrics of a subscription with their labels
+// already applied.
+type subscriptionMetrics struct {
+	delivered      prometheus.Counter
+	acked          prometheus.Counter
+	nackedRequeue  prometheus.Counter
+	nackedDiscard  prometheus.Counter
+	decodeErrors   prometheus.Counter
+	handlerSeconds prometheus.Observer
+}
+
+func newSubscriptionMetrics(queue, key string) subscriptionMetrics {
+	return subscriptionMetrics{
+		delivered:      deliveredTotal.WithLabelValues(queue, key),
+		acked:          ackedTotal.WithLabelValues(queue, key),
+		nackedRequeue:  nackedRequeueTotal.WithLabelValues(queue, key),
+		nackedDiscard:  nackedDiscardTotal.WithLabelValues(queue, key),
+		decodeErrors:   decodeErrorsTotal.WithLabelValues(queue, key),
+		handlerSeconds: handlerSeconds.WithLabelValues(queue, key),
+	}
+}
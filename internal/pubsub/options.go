@@ -0,0 +1,111 @@
+package pubsub
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type subscribeOptions struct {
+	filter             DeliveryFilter
+	concurrency        int
+	prefetch           int
+	deadLetterExchange string
+	exclusive          bool
+}
+
+type SubscribeOption func(*subscribeOptions)
+
+type publishOptions struct {
+	mandatory bool
+}
+
+type PublishOption func(*publishOptions)
+
+// WithMandatory has the broker return the message when no queue is bound to
+// its key, which a Channel counts, instead of dropping it.
+func WithMandatory() PublishOption {
+	return func(o *publishOptions) {
+		o.mandatory = true
+	}
+}
+
+// Rejection tells a subscriber to refuse a delivery without handling it.
+type Rejection struct {
+	Reason string
+	// DeadLetter republishes the delivery to the dead letter exchange with the
+	// reason in RejectReasonHeader, otherwise the delivery is dropped.
+	DeadLetter bool
+}
+
+// DeliveryFilter inspects a delivery before it is decoded and returns a
+// non-nil Rejection to keep it from reaching the handler.
+type DeliveryFilter func(d amqp.Delivery) *Rejection
+
+func WithDeliveryFilter(filter DeliveryFilter) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.filter = filter
+	}
+}
+
+// WithConcurrency runs n handlers at once, so handlers which block until
+// some batched work completes don't hold up each other.
+func WithConcurrency(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.concurrency = max(1, n)
+	}
+}
+
+// WithPrefetch caps the deliveries waiting for an acknowledgement. It is
+// raised to the concurrency if lower.
+func WithPrefetch(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.prefetch = n
+	}
+}
+
+// WithDeadLetterExchange replaces DeadLetterExchange for the queue.
+func WithDeadLetterExchange(exchange string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.deadLetterExchange = exchange
+	}
+}
+
+// WithExclusiveConsumer makes the subscription the only consumer of the
+// queue. Subscribing fails while another one consumes it.
+func WithExclusiveConsumer() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.exclusive = true
+	}
+}
+
+func reject(channel *amqp.Channel, exchange string, d amqp.Delivery, rejection *Rejection) {
+	if !rejection.DeadLetter {
+		slog.Info("dropped rejected message", logging.KeyRoutingKey, d.RoutingKey, logging.KeyMessageID, d.MessageId, "reason", rejection.Reason)
+		d.Ack(false)
+		return
+	}
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[RejectReasonHeader] = rejection.Reason
+	err := channel.PublishWithContext(context.Background(), exchange, d.RoutingKey, false, false, amqp.Publishing{
+		Headers:     headers,
+		ContentType: d.ContentType,
+		MessageId:   d.MessageId,
+		Timestamp:   d.Timestamp,
+		Body:        d.Body,
+	})
+	if err != nil {
+		// Let the queue dead-letter it, even though the reason gets lost.
+		slog.Error("could not dead-letter message", logging.KeyRoutingKey, d.RoutingKey, logging.KeyMessageID, d.MessageId, logging.Err(err))
+		d.Nack(false, false)
+		return
+	}
+	slog.Info("dead-lettered rejected message", logging.KeyExchange, exchange, logging.KeyRoutingKey, d.RoutingKey, logging.KeyMessageID, d.MessageId, "reason", rejection.Reason)
+	d.Ack(false)
+}
@@ -0,0 +1,160 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Publisher is implemented by *amqp.Channel and *Channel.
+type Publisher interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+type limiter struct {
+	limit  RateLimit
+	bucket *tokenBucket
+}
+
+// Channel wraps an AMQP channel used for publishing. It throttles
+// publications according to its rate limits and holds publishers back while
+// the broker has blocked the connection instead of letting them fail.
+// Publications are confirmed by the broker, which is reflected in the
+// metrics.
+type Channel struct {
+	ch       *amqp.Channel
+	limiters []limiter
+
+	mu      sync.Mutex
+	ready   chan struct{} // closed while the connection isn't blocked
+	blocked bool
+}
+
+type publication struct {
+	exchange string
+	key      string
+}
+
+func NewChannel(conn *amqp.Connection, limits ...RateLimit) (*Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("error during channel creation: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("error while enabling publisher confirms: %w", err)
+	}
+
+	c := &Channel{
+		ch:    ch,
+		ready: make(chan struct{}),
+	}
+	close(c.ready)
+	for _, l := range limits {
+		if l.Rate <= 0 {
+			continue
+		}
+		c.limiters = append(c.limiters, limiter{limit: l, bucket: newTokenBucket(l.Rate, l.Burst)})
+	}
+
+	go c.watchBlocked(conn.NotifyBlocked(make(chan amqp.Blocking, 1)))
+	go c.watchReturns(ch.NotifyReturn(make(chan amqp.Return, 16)))
+	return c, nil
+}
+
+// awaitConfirm counts the confirmation of a publication. The channel fails
+// the publications it hasn't confirmed yet when it closes.
+func (c *Channel) awaitConfirm(confirmation *amqp.DeferredConfirmation, p publication) {
+	<-confirmation.Done()
+	switch {
+	case confirmation.Acked():
+		confirmedTotal.WithLabelValues(p.exchange, p.key).Inc()
+	case c.ch.IsClosed():
+		slog.Warn("channel closed before the broker confirmed message", logging.KeyExchange, p.exchange, logging.KeyRoutingKey, p.key)
+	default:
+		slog.Warn("broker refused message", logging.KeyExchange, p.exchange, logging.KeyRoutingKey, p.key)
+	}
+}
+
+func (c *Channel) watchReturns(returns <-chan amqp.Return) {
+	for r := range returns {
+		returnedTotal.WithLabelValues(r.Exchange, keyPattern(r.RoutingKey)).Inc()
+	}
+}
+
+func (c *Channel) watchBlocked(blockings <-chan amqp.Blocking) {
+	for b := range blockings {
+		c.setBlocked(b.Active)
+		if b.Active {
+			slog.Warn("broker blocked publishing", "reason", b.Reason)
+		} else {
+			slog.Info("broker unblocked publishing")
+		}
+	}
+	// The connection is gone, let waiting publishers fail on the channel.
+	c.setBlocked(false)
+}
+
+func (c *Channel) setBlocked(blocked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if blocked == c.blocked {
+		return
+	}
+	c.blocked = blocked
+	if blocked {
+		c.ready = make(chan struct{})
+	} else {
+		close(c.ready)
+	}
+}
+
+func (c *Channel) waitUnblocked(ctx context.Context) error {
+	c.mu.Lock()
+	ready := c.ready
+	c.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Channel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	for _, l := range c.limiters {
+		if l.limit.matches(exchange, key) {
+			if err := l.bucket.wait(ctx); err != nil {
+				return fmt.Errorf("error while waiting for rate limit: %w", err)
+			}
+			break
+		}
+	}
+	if err := c.waitUnblocked(ctx); err != nil {
+		return fmt.Errorf("error while waiting for connection to unblock: %w", err)
+	}
+
+	// The channel numbers the message as it is sent, so publishers wait
+	// neither on each other nor on the confirms.
+	confirmation, err := c.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, mandatory, immediate, msg)
+	if err != nil {
+		return err
+	}
+	pattern := keyPattern(key)
+	publishedTotal.WithLabelValues(exchange, pattern).Inc()
+	go c.awaitConfirm(confirmation, publication{exchange: exchange, key: pattern})
+	return nil
+}
+
+func (c *Channel) IsClosed() bool {
+	return c.ch.IsClosed()
+}
+
+func (c *Channel) Close() error {
+	return c.ch.Close()
+}
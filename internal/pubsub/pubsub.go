@@ -3,11 +3,17 @@ package pubsub
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logging"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type SimpleQueueType string 
@@ -17,6 +23,13 @@ const (
 
 )
 
+// DeadLetterExchange receives the messages rejected by subscribers.
+const DeadLetterExchange = "peril_dlx"
+
+// RejectReasonHeader carries the reason a delivery was dead-lettered by a
+// DeliveryFilter.
+const RejectReasonHeader = "x-peril-reject-reason"
+
 type AckType int 
 const (
 	Ack AckType = iota
@@ -24,22 +37,48 @@ const (
 	NackDiscard
 )
 
-func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
+func PublishJSON[T any](ctx context.Context, ch Publisher, exchange, key string, val T, opts ...PublishOption) error {
 	jsonData, err := json.Marshal(val)
 	if err != nil {
 		return fmt.Errorf("error while marshalling val to JSON: %w", err)
 	}
-	return ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{ContentType: "application/json", Body: jsonData})
+	return publish(ctx, ch, exchange, key, amqp.Publishing{ContentType: "application/json", Body: jsonData}, opts)
 }
 
-func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
+func PublishGob[T any](ctx context.Context, ch Publisher, exchange, key string, val T, opts ...PublishOption) error {
 	var data bytes.Buffer
 	enc := gob.NewEncoder(&data)
 	err := enc.Encode(val)
 	if err != nil {
 		return fmt.Errorf("error while marshalling val to gob: %w", err)
 	}
-	return ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{ContentType: "application/gob", Body: data.Bytes()})
+	return publish(ctx, ch, exchange, key, amqp.Publishing{ContentType: "application/gob", Body: data.Bytes()}, opts)
+}
+
+// publish sends msg with a new message ID and the trace context of ctx in
+// its headers.
+func publish(ctx context.Context, ch Publisher, exchange, key string, msg amqp.Publishing, opts []PublishOption) error {
+	var options publishOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	msg.MessageId = newMessageID()
+	ctx, span := startPublishSpan(ctx, exchange, key, msg.MessageId)
+	defer span.End()
+	msg.Headers = injectTraceContext(ctx, msg.Headers)
+	err := ch.PublishWithContext(ctx, exchange, key, options.mandatory, false, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// newMessageID identifies a message in the logs of every consumer.
+func newMessageID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
 }
 
 func DeclareAndBind(
@@ -48,13 +87,24 @@ func DeclareAndBind(
 	queueName,
 	key string,
 	queueType SimpleQueueType, // an enum to represent "durable" or "transient"
+) (*amqp.Channel, amqp.Queue, error) {
+	return declareAndBind(conn, exchange, queueName, key, queueType, DeadLetterExchange)
+}
+
+func declareAndBind(
+	conn *amqp.Connection,
+	exchange,
+	queueName,
+	key string,
+	queueType SimpleQueueType,
+	deadLetterExchange string,
 ) (*amqp.Channel, amqp.Queue, error) {
 		channel, err := conn.Channel()
 		if err != nil {
 			return nil, amqp.Queue{}, fmt.Errorf("error during channel creation: %w", err)
 		}
 
-		queue, err := channel.QueueDeclare(queueName, queueType == "durable", queueType == "transient", queueType == "transient", false, amqp.Table{"x-dead-letter-exchange": "peril_dlx"})
+		queue, err := channel.QueueDeclare(queueName, queueType == "durable", queueType == "transient", queueType == "transient", false, amqp.Table{"x-dead-letter-exchange": deadLetterExchange})
 		if err != nil {
 			return nil, amqp.Queue{}, fmt.Errorf("error during queue declaration: %w", err)
 		}
@@ -73,48 +123,120 @@ func subscribe[T any](
 	queueName,
 	key string,
 	queueType SimpleQueueType,
-	handler func(T) AckType,
+	handler func(context.Context, T) AckType,
 	unmarshaller func([]byte) (T, error),
-) error {
-	channel, _, err := DeclareAndBind(conn, exchange, queueName, key, queueType)
+	opts ...SubscribeOption,
+) (*Subscription, error) {
+	options := subscribeOptions{concurrency: 1, prefetch: 10, deadLetterExchange: DeadLetterExchange}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	channel, queue, err := declareAndBind(conn, exchange, queueName, key, queueType, options.deadLetterExchange)
 	if err != nil {
-		return fmt.Errorf("error while binding queue: %w", err)
+		return nil, fmt.Errorf("error while binding queue: %w", err)
+	}
+	if options.filter != nil {
+		// Rejected deliveries may be republished to the dead letter exchange.
+		err = channel.ExchangeDeclare(options.deadLetterExchange, amqp.ExchangeFanout, true, false, false, false, nil)
+		if err != nil {
+			channel.Close()
+			return nil, fmt.Errorf("error while declaring dead letter exchange: %w", err)
+		}
 	}
-	channel.Qos(10, 0, false)
-	deliveries, err := channel.Consume(queueName, "", false, false, false, false, nil)
+	channel.Qos(max(options.prefetch, options.concurrency), 0, false)
+	m := newSubscriptionMetrics(queue.Name, key)
+	// The broker names the queue when queueName is empty.
+	sub := newSubscription(channel, exchange, queue.Name, key, options.concurrency)
+	deliveries, err := channel.Consume(queue.Name, sub.consumerTag, false, options.exclusive, false, false, nil)
 	if err != nil {
-		return fmt.Errorf("error while consuming queue: %w", err)
+		channel.Close()
+		return nil, fmt.Errorf("error while consuming queue: %w", err)
 	}
 
-	go func() {
-		for d := range deliveries {
-			value, err := unmarshaller(d.Body)
-			if err == nil {
-				switch handler(value) {
-				case Ack:
-					err := d.Ack(false)
-					if err != nil {
-						fmt.Printf("err while acknowledge: %v", err)
-					} else {
-					 fmt.Println("Message acknowledged")
-					}
-					continue
-				case NackRequeue:
-					d.Nack(false, true)
-					// fmt.Println("Message requeued")
-					continue
-				case NackDiscard:
-					d.Nack(false, false)
-					// fmt.Println("Message discarded")
-					continue
+	process := func(d amqp.Delivery) {
+		sub.stats.delivered.Add(1)
+		m.delivered.Inc()
+		if sub.isClosing() {
+			d.Nack(false, true)
+			return
+		}
+
+		ctx, span := startDeliverSpan(d, queue.Name)
+		defer span.End()
+		if options.filter != nil {
+			if rejection := options.filter(d); rejection != nil {
+				sub.stats.rejected.Add(1)
+				m.nackedDiscard.Inc()
+				span.SetAttributes(outcomeAttr("rejected"))
+				reject(channel, options.deadLetterExchange, d, rejection)
+				return
+			}
+		}
+		value, err := unmarshaller(d.Body)
+		if err == nil {
+			sub.stats.inFlight.Add(1)
+			start := time.Now()
+			ack := runHandler(ctx, queue.Name, handler, value)
+			m.handlerSeconds.Observe(time.Since(start).Seconds())
+			sub.stats.inFlight.Add(-1)
+			switch ack {
+			case Ack:
+				sub.stats.acked.Add(1)
+				m.acked.Inc()
+				span.SetAttributes(outcomeAttr("ack"))
+				if err := d.Ack(false); err != nil {
+					logDelivery(slog.LevelError, "could not acknowledge message", queue.Name, d, "ack", logging.Err(err))
+					return
 				}
+				logDelivery(slog.LevelDebug, "message acknowledged", queue.Name, d, "ack")
+				return
+			case NackRequeue:
+				sub.stats.requeued.Add(1)
+				m.nackedRequeue.Inc()
+				span.SetAttributes(outcomeAttr("nack_requeue"))
+				d.Nack(false, true)
+				logDelivery(slog.LevelDebug, "message requeued", queue.Name, d, "nack_requeue")
+				return
+			case NackDiscard:
+				sub.stats.discarded.Add(1)
+				m.nackedDiscard.Inc()
+				span.SetAttributes(outcomeAttr("nack_discard"))
+				d.Nack(false, false)
+				logDelivery(slog.LevelInfo, "message discarded", queue.Name, d, "nack_discard")
+				return
 			}
-			d.Nack(false, false)
-			// fmt.Println("Error decoding message")
 		}
-	}()
+		sub.stats.decodeErrors.Add(1)
+		m.decodeErrors.Inc()
+		m.nackedDiscard.Inc()
+		span.SetAttributes(outcomeAttr("decode_error"))
+		d.Nack(false, false)
+		logDelivery(slog.LevelWarn, "could not decode message", queue.Name, d, "decode_error", logging.Err(err))
+	}
+
+	for range options.concurrency {
+		sub.handlers.Add(1)
+		go func() {
+			defer sub.handlers.Done()
+			for d := range deliveries {
+				process(d)
+			}
+		}()
+	}
+	go sub.watch()
+
+	return sub, nil
+}
 
-	return nil
+func logDelivery(level slog.Level, msg, queue string, d amqp.Delivery, outcome string, attrs ...slog.Attr) {
+	attrs = append(attrs,
+		slog.String(logging.KeyQueue, queue),
+		slog.String(logging.KeyRoutingKey, d.RoutingKey),
+		slog.String(logging.KeyMessageID, d.MessageId),
+		slog.String(logging.KeyOutcome, outcome),
+	)
+	slog.LogAttrs(context.Background(), level, msg, attrs...)
 }
 
 func SubscribeJSON[T any](
@@ -123,13 +245,14 @@ func SubscribeJSON[T any](
     queueName,
     key string,
     queueType SimpleQueueType, // an enum to represent "durable" or "transient"
-    handler func(T) AckType,
-) error {
+    handler func(context.Context, T) AckType,
+    opts ...SubscribeOption,
+) (*Subscription, error) {
 	return subscribe(conn, exchange, queueName, key, queueType, handler, func(b []byte) (T, error) {
 		var value T
 		err := json.Unmarshal(b, &value)
 		return value, err
-	})
+	}, opts...)
 }
 
 func SubscribeGob[T any](
@@ -138,13 +261,14 @@ func SubscribeGob[T any](
     queueName,
     key string,
     queueType SimpleQueueType, // an enum to represent "durable" or "transient"
-    handler func(T) AckType,
-) error {
+    handler func(context.Context, T) AckType,
+    opts ...SubscribeOption,
+) (*Subscription, error) {
 	return subscribe(conn, exchange, queueName, key, queueType, handler, func(b []byte) (T, error) {
 		buffer := bytes.NewBuffer(b)
 		dec := gob.NewDecoder(buffer)
 		var value T
 		err := dec.Decode(&value)
 		return value, err
-	})
+	}, opts...)
 }
\ No newline at end of file
@@ -0,0 +1,157 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Subscription is a running consumer started by SubscribeJSON or
+// SubscribeGob.
+type Subscription struct {
+	channel     *amqp.Channel
+	exchange    string
+	queue       string
+	key         string
+	consumerTag string
+	concurrency int
+
+	handlers sync.WaitGroup
+	stopped  chan struct{} // closed once every handler returned
+	closing  chan struct{}
+	once     sync.Once
+	stats    subscriptionStats
+}
+
+type subscriptionStats struct {
+	delivered    atomic.Int64
+	acked        atomic.Int64
+	requeued     atomic.Int64
+	discarded    atomic.Int64
+	rejected     atomic.Int64
+	decodeErrors atomic.Int64
+	inFlight     atomic.Int64
+}
+
+// SubscriptionInfo describes a subscription and counts what happened to its
+// deliveries so far.
+type SubscriptionInfo struct {
+	Exchange     string `json:"exchange"`
+	Queue        string `json:"queue"`
+	Key          string `json:"key"`
+	ConsumerTag  string `json:"consumer_tag"`
+	Concurrency  int    `json:"concurrency"`
+	Closed       bool   `json:"closed"`
+	Alive        bool   `json:"alive"`
+	Delivered    int64  `json:"delivered"`
+	Acked        int64  `json:"acked"`
+	Requeued     int64  `json:"requeued"`
+	Discarded    int64  `json:"discarded"`
+	Rejected     int64  `json:"rejected"`
+	DecodeErrors int64  `json:"decode_errors"`
+	InFlight     int64  `json:"in_flight"`
+}
+
+func newSubscription(channel *amqp.Channel, exchange, queue, key string, concurrency int) *Subscription {
+	return &Subscription{
+		channel:     channel,
+		exchange:    exchange,
+		queue:       queue,
+		key:         key,
+		consumerTag: consumerTag(queue),
+		concurrency: concurrency,
+		stopped:     make(chan struct{}),
+		closing:     make(chan struct{}),
+	}
+}
+
+// watch notices when the handlers stop, which happens when the consumer is
+// cancelled or its channel is closed, whether by Close or by the broker.
+func (s *Subscription) watch() {
+	s.handlers.Wait()
+	close(s.stopped)
+}
+
+// Alive reports whether the consumer is still receiving deliveries.
+func (s *Subscription) Alive() bool {
+	select {
+	case <-s.stopped:
+		return false
+	default:
+		return !s.channel.IsClosed()
+	}
+}
+
+func (s *Subscription) Queue() string {
+	return s.queue
+}
+
+func (s *Subscription) Info() SubscriptionInfo {
+	return SubscriptionInfo{
+		Exchange:     s.exchange,
+		Queue:        s.queue,
+		Key:          s.key,
+		ConsumerTag:  s.consumerTag,
+		Concurrency:  s.concurrency,
+		Closed:       s.isClosing() || s.channel.IsClosed(),
+		Alive:        s.Alive(),
+		Delivered:    s.stats.delivered.Load(),
+		Acked:        s.stats.acked.Load(),
+		Requeued:     s.stats.requeued.Load(),
+		Discarded:    s.stats.discarded.Load(),
+		Rejected:     s.stats.rejected.Load(),
+		DecodeErrors: s.stats.decodeErrors.Load(),
+		InFlight:     s.stats.inFlight.Load(),
+	}
+}
+
+func consumerTag(queue string) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%s", queue, hex.EncodeToString(suffix))
+}
+
+func (s *Subscription) isClosing() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops consuming and waits for the running handlers to return before
+// closing the channel. Deliveries that haven't reached a handler yet are
+// requeued, as are the ones still being handled when ctx is done.
+func (s *Subscription) Close(ctx context.Context) error {
+	var err error
+	s.once.Do(func() {
+		close(s.closing)
+		if cerr := s.channel.Cancel(s.consumerTag, false); cerr != nil {
+			err = fmt.Errorf("error while cancelling consumer: %w", cerr)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			s.handlers.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if err == nil {
+				err = fmt.Errorf("error while waiting for handlers: %w", ctx.Err())
+			}
+		}
+
+		if cerr := s.channel.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("error while closing channel: %w", cerr)
+		}
+	})
+	return err
+}
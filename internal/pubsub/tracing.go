@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub")
+
+// headerCarrier lets the propagator read and write AMQP headers.
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func injectTraceContext(ctx context.Context, headers amqp.Table) amqp.Table {
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+	return headers
+}
+
+func startPublishSpan(ctx context.Context, exchange, key, messageID string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "publish "+keyPattern(key),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", key),
+			attribute.String("messaging.message.id", messageID),
+		),
+	)
+}
+
+// startDeliverSpan continues the trace of the publisher of d.
+func startDeliverSpan(d amqp.Delivery, queue string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(d.Headers))
+	return tracer.Start(ctx, "deliver "+queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", d.Exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", d.RoutingKey),
+			attribute.String("messaging.message.id", d.MessageId),
+		),
+	)
+}
+
+func runHandler[T any](ctx context.Context, queue string, handler func(context.Context, T) AckType, value T) AckType {
+	ctx, span := tracer.Start(ctx, "handle "+queue)
+	defer span.End()
+	return handler(ctx, value)
+}
+
+func outcomeAttr(outcome string) attribute.KeyValue {
+	return attribute.String("peril.outcome", outcome)
+}
@@ -6,8 +6,37 @@ type PlayingState struct {
 	IsPaused bool
 }
 
+type TurnPhase string
+
+const (
+	TurnStart TurnPhase = "start"
+	TurnEnd   TurnPhase = "end"
+)
+
+// TurnEvent is published when a turn starts and when it ends, in games
+// played in turns.
+type TurnEvent struct {
+	Turn  int
+	Phase TurnPhase
+	// Ends is when the turn ends.
+	Ends time.Time
+}
+
 type GameLog struct {
 	CurrentTime time.Time
 	Message     string
 	Username    string
 }
+
+// PlayerPresence is published by clients when they join, periodically while
+// they play, and when they quit.
+type PlayerPresence struct {
+	Username string
+	Online   bool
+	Time     time.Time
+	// RulesetHash identifies the rules the client plays by.
+	RulesetHash string
+	// Session is drawn by the client when it starts, the server answers the
+	// first presence of every session.
+	Session string
+}
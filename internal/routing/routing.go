@@ -1,5 +1,7 @@
 package routing
 
+import "time"
+
 const (
 	ArmyMovesPrefix = "army_moves"
 
@@ -8,8 +10,28 @@ const (
 	PauseKey = "pause"
 
 	GameLogSlug = "game_logs"
+
+	PresencePrefix = "presence"
+
+	// WorldStatePrefix carries the server's view of a player's units.
+	WorldStatePrefix = "world"
+
+	// TreasuryPrefix carries a player's treasury after every income tick.
+	TreasuryPrefix = "treasury"
+
+	// TurnPrefix carries the start and the end of turns, OrdersPrefix the
+	// orders players give during a turn.
+	TurnPrefix   = "turn"
+	OrdersPrefix = "orders"
+
+	// CasualtiesPrefix carries the units killed in the wars the server
+	// resolved.
+	CasualtiesPrefix = "casualties"
 )
 
+// PresenceInterval is how often clients announce they are still playing.
+const PresenceInterval = 15 * time.Second
+
 const (
 	ExchangePerilDirect = "peril_direct"
 	ExchangePerilTopic  = "peril_topic"
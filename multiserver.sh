@@ -17,15 +17,27 @@ cleanup() {
   for pid in "${pids[@]}"; do
     kill -SIGTERM "$pid"
   done
+  wait
   exit
 }
 
 # Setup trap for SIGINT
 trap 'cleanup' SIGINT
 
-# Start the specified number of instances of the program in the background
+# Build once so the signals reach the servers rather than `go run`
+bin="$(mktemp -d)/peril-server"
+go build -o "$bin" ./cmd/server || exit 1
+
+# Start the specified number of daemons in the background, they compete for
+# the game_logs queue. Only the first one owns the world, the moves, spawns
+# and orders of the players go to it. Each one can be controlled through its
+# admin socket, e.g. `echo pause | nc -U peril-server-0.sock`.
 for (( i=0; i<num_instances; i++ )); do
-  go run ./cmd/server &
+  world=false
+  if (( i == 0 )); then
+    world=true
+  fi
+  "$bin" --daemon --world="$world" --admin-socket "peril-server-$i.sock" &
   pids+=($!)
 done
 